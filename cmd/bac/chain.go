@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// errBrokenChain makes verify fail when problems were found.
+var errBrokenChain = errors.New("the chain is broken")
+
+// chain verifies the entry chain of a customer or seals one of its billing periods:
+//
+//	bac chain verify <customer>
+//	bac chain seal <customer> <from> <to>
+//
+// Customers are given by ID or name, dates as YYYY-MM-DD in the timezone of the store and
+// the period sealed goes from the start of from to the end of to.
+func chain(args []string) error {
+	flags := flag.NewFlagSet("chain", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) < 2 {
+		return fmt.Errorf("want verify <customer> or seal <customer> <from> <to>")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	c, err := s.FindCustomer(args[1])
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "verify":
+		if len(args) != 2 {
+			return fmt.Errorf("want verify <customer>")
+		}
+		problems, err := s.VerifyChain(c.ID)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%d problems: %w", len(problems), errBrokenChain)
+		}
+		fmt.Printf("chain of %s is intact\n", c.Name)
+		return nil
+	case "seal":
+		if len(args) != 4 {
+			return fmt.Errorf("want seal <customer> <from> <to>")
+		}
+		from, to, err := parsePeriod(args[2], args[3], s.Location())
+		if err != nil {
+			return err
+		}
+		seal, err := s.Seal(c.ID, from, to)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("sealed %s from %s to %s as record %d\n%s\n", c.Name, args[2], args[3], seal.Seq, seal.Hash)
+		return nil
+	default:
+		return fmt.Errorf("unknown chain command %q", args[0])
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"ballandchain/idle"
+	"ballandchain/storage"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// watchIdle watches the screensaver of the session bus until interrupted and, when coming back
+// to a running timer, asks whether to keep, discard or reassign the time spent away:
+//
+//	bac idle [-min <duration>]
+func watchIdle(args []string) error {
+	flags := flag.NewFlagSet("idle", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	minIdle := flags.Duration("min", idle.DefaultMinIdle, "shortest time away asked about")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return fmt.Errorf("want no arguments")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		// a second interrupt gets through while waiting for an answer
+		<-ctx.Done()
+		stop()
+	}()
+	answers := bufio.NewReader(os.Stdin)
+	m := &idle.Monitor{
+		Store:   s,
+		Source:  idle.DBus{},
+		MinIdle: *minIdle,
+		Ask: func(e *storage.Entry, from, to time.Time) (idle.Decision, error) {
+			return askIdle(s, answers, e, from, to)
+		},
+	}
+	fmt.Println("watching for idleness, interrupt to stop")
+	return m.Run(ctx)
+}
+
+// askIdle asks what to do with the time a running entry spent idle until given a valid answer:
+// k to keep it, d to discard it or r <task> to book it to a task of the same customer, or of
+// another one given as <customer>/<task>.
+func askIdle(s *storage.Store, answers *bufio.Reader, e *storage.Entry, from, to time.Time) (idle.Decision, error) {
+	loc := s.Location()
+	for {
+		fmt.Printf("%s was idle from %s to %s (%s), [k]eep, [d]iscard or [r]eassign <task>? ", describeTask(e.Task),
+			formatStart(from.In(loc), to.In(loc)), formatStart(to.In(loc), to.In(loc)), formatDuration(to.Sub(from)))
+		line, err := answers.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			// nobody to ask, the time stays
+			return idle.Decision{Action: storage.KeepIdle}, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return idle.Decision{}, err
+		}
+		answer, ref, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch strings.ToLower(answer) {
+		case "k", "keep":
+			return idle.Decision{Action: storage.KeepIdle}, nil
+		case "d", "discard":
+			return idle.Decision{Action: storage.DiscardIdle}, nil
+		case "r", "reassign":
+			t, err := reassignTask(s, e, strings.TrimSpace(ref))
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			return idle.Decision{Action: storage.ReassignIdle, Task: t}, nil
+		}
+	}
+}
+
+// reassignTask resolves the task idle time is reassigned to, of the customer of e unless given
+// as <customer>/<task>.
+func reassignTask(s *storage.Store, e *storage.Entry, ref string) (*storage.Task, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("want r <task>")
+	}
+	var t *storage.Task
+	var err error
+	if customer, task, ok := strings.Cut(ref, "/"); ok {
+		t, err = findTask(s, customer, task)
+	} else {
+		t, err = s.FindTask(e.Task.Customer.ID, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, checkActive(t)
+}
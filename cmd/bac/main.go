@@ -0,0 +1,141 @@
+// Command bac works with a Ball & Chain data root from the command line.
+package main
+
+import (
+	"ballandchain/storage"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// usage is printed when the command line cannot be understood.
+const usage = `usage: bac <command> [-root <dir>] [arguments]
+
+Every command working on a data root takes -root, it defaults to BAC_ROOT_FOLDER or ~/.ballandchain.
+
+commands:
+  start [-m <comment>] <customer> <task>       start a timer, stopping the running one
+  stop [<customer>]                            stop the running timer
+  switch [-m <comment>] <customer> <task>      stop the running timers and start another one
+  status                                       show the running timer, whatever day it started
+  pause [<customer>]                           pause the running timer
+  resume [<customer>]                          resume the paused timer
+  log [-from <date>] [-to <date>] [<customer>] list the entries of the last week
+  add -from <time> -to <time> [-m <comment>] <customer> <task>
+                                               record an entry after the fact
+  idle [-min <duration>]                       ask what to do with the time away from a running timer
+
+  customer add <name>                          add a customer
+  customer list [-all]                         list the customers, archived ones too with -all
+  customer rename <customer> <name>            rename a customer
+  customer archive [-undo] <customer>          hide a customer from listings and searches
+  customer show <customer>                     show a customer with the time spent on its tasks
+  task add [-id <external id>] <customer> <name>
+                                               add a task to a customer
+  task list [-all] <customer>                  list the tasks of a customer
+  task rename <customer> <task> <name>         rename a task
+  task archive [-undo] <customer> <task>       hide a task from listings and searches
+  task move <customer> <task> <to customer>    move a task and its entries to another customer
+
+  chain verify <customer>                      report entries changed behind the chain of a customer
+  chain seal <customer> <from> <to>            seal the entries of a customer between two dates
+  export [-o <file>] <customer> <from> <to>    write the signed timesheet of a customer
+  key generate|rotate|show                     manage the key signing timesheets
+  merge-driver <base> <ours> <theirs> <path>   merge a data file, meant to be run by git
+  reindex                                      rebuild the search indexes
+  verify [-fingerprint <fingerprint>] <file>   check the signature of an exported timesheet
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "start":
+		err = start(os.Args[2:])
+	case "stop":
+		err = stop(os.Args[2:])
+	case "switch":
+		err = switchTask(os.Args[2:])
+	case "status":
+		err = status(os.Args[2:])
+	case "pause":
+		err = pause(os.Args[2:])
+	case "resume":
+		err = resume(os.Args[2:])
+	case "log":
+		err = logEntries(os.Args[2:])
+	case "add":
+		err = add(os.Args[2:])
+	case "idle":
+		err = watchIdle(os.Args[2:])
+	case "customer":
+		err = customer(os.Args[2:])
+	case "task":
+		err = task(os.Args[2:])
+	case "chain":
+		err = chain(os.Args[2:])
+	case "export":
+		err = export(os.Args[2:])
+	case "key":
+		err = key(os.Args[2:])
+	case "merge-driver":
+		err = mergeDriver(os.Args[2:])
+	case "reindex":
+		err = reindex(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bac %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// openStore opens the given data root, the default one if empty.
+func openStore(root string) (*storage.Store, error) {
+	if root == "" {
+		var err error
+		if root, err = storage.DefaultRoot(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("data root: %w", err)
+	}
+	s, err := storage.Open(root, storage.Options{Warn: warn})
+	if err != nil {
+		return nil, err
+	}
+	for _, leftover := range s.Leftovers() {
+		warn(fmt.Errorf("removed leftover temporary file %s from an interrupted write", leftover))
+	}
+	return s, nil
+}
+
+// warn reports on stderr the problems the store works around, so they stay out of the output.
+func warn(err error) {
+	fmt.Fprintf(os.Stderr, "bac: %v\n", err)
+}
+
+// parseArgs parses flags given anywhere among the positional arguments and returns the latter,
+// so both "bac start -m fix acme web" and "bac start acme web -m fix" work.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
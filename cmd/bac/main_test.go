@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		want        []string
+		wantComment string
+		wantErr     bool
+	}{
+		{
+			name: "positional only",
+			args: []string{"acme", "web"},
+			want: []string{"acme", "web"},
+		},
+		{
+			name:        "flags first",
+			args:        []string{"-m", "fix", "acme", "web"},
+			want:        []string{"acme", "web"},
+			wantComment: "fix",
+		},
+		{
+			name:        "flags last",
+			args:        []string{"acme", "web", "-m", "fix"},
+			want:        []string{"acme", "web"},
+			wantComment: "fix",
+		},
+		{
+			name:        "flags in between",
+			args:        []string{"acme", "-m=fix", "web"},
+			want:        []string{"acme", "web"},
+			wantComment: "fix",
+		},
+		{
+			name:        "after the terminator",
+			args:        []string{"acme", "--", "-web"},
+			want:        []string{"acme", "-web"},
+			wantComment: "",
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"acme", "-x"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("start", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			comment := flags.String("m", "", "comment of the entry")
+			got, err := parseArgs(flags, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) || *comment != tt.wantComment {
+				t.Errorf("parseArgs() = %q with -m %q, want %q with -m %q", got, *comment, tt.want, tt.wantComment)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"ballandchain/storage"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// customer manages the customers of the data root:
+//
+//	bac customer add <name>
+//	bac customer list [-all]
+//	bac customer rename <customer> <name>
+//	bac customer archive [-undo] <customer>
+//	bac customer show <customer>
+//
+// Customers are given by ID or name, archived ones are only listed with -all.
+func customer(args []string) error {
+	flags := flag.NewFlagSet("customer", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	all := flags.Bool("all", false, "list archived customers too")
+	undo := flags.Bool("undo", false, "bring an archived customer back")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("want add, list, rename, archive or show")
+	}
+	command, positional := positional[0], positional[1:]
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	switch command {
+	case "add":
+		if len(positional) != 1 {
+			return fmt.Errorf("want add <name>")
+		}
+		if _, err := s.FindCustomer(positional[0]); !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("customer %q already exists", positional[0])
+		}
+		c := storage.NewCustomer(positional[0])
+		if err := s.AddCustomer(c); err != nil {
+			return err
+		}
+		fmt.Printf("added customer %s %s\n", c.Name, c.ID)
+		return nil
+	case "list":
+		if len(positional) != 0 {
+			return fmt.Errorf("want list [-all]")
+		}
+		for _, c := range s.Customers() {
+			if c.Archived && !*all {
+				continue
+			}
+			fmt.Printf("%s  %s%s\n", c.ID, c.Name, formatArchived(c.Archived))
+		}
+		return nil
+	case "rename":
+		if len(positional) != 2 {
+			return fmt.Errorf("want rename <customer> <name>")
+		}
+		c, err := s.FindCustomer(positional[0])
+		if err != nil {
+			return err
+		}
+		if _, err := s.FindCustomer(positional[1]); err == nil {
+			return fmt.Errorf("customer %q already exists", positional[1])
+		}
+		if _, err := s.RenameCustomer(c.ID, positional[1]); err != nil {
+			return err
+		}
+		fmt.Printf("renamed customer %s to %s\n", c.Name, positional[1])
+		return nil
+	case "archive":
+		if len(positional) != 1 {
+			return fmt.Errorf("want archive [-undo] <customer>")
+		}
+		c, err := s.FindCustomer(positional[0])
+		if err != nil {
+			return err
+		}
+		if _, err := s.ArchiveCustomer(c.ID, !*undo); err != nil {
+			return err
+		}
+		if *undo {
+			fmt.Printf("unarchived customer %s\n", c.Name)
+		} else {
+			fmt.Printf("archived customer %s\n", c.Name)
+		}
+		return nil
+	case "show":
+		if len(positional) != 1 {
+			return fmt.Errorf("want show <customer>")
+		}
+		c, err := s.FindCustomer(positional[0])
+		if err != nil {
+			return err
+		}
+		return showCustomer(s, c)
+	default:
+		return fmt.Errorf("unknown customer command %q", command)
+	}
+}
+
+// showCustomer prints a customer along with its tasks and the time spent on each of them, gross
+// and net of breaks.
+func showCustomer(s *storage.Store, c *storage.Customer) error {
+	tasks, err := sortedTasks(s, c.ID)
+	if err != nil {
+		return err
+	}
+	entries, err := s.LoadEntries(storage.EntryFilter{CustomerID: c.ID})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	spent, spentNet := map[uuid.UUID]time.Duration{}, map[uuid.UUID]time.Duration{}
+	var total, totalNet time.Duration
+	for _, e := range entries {
+		spent[e.Task.ID] += e.Duration(now)
+		spentNet[e.Task.ID] += e.NetDuration(now)
+		total += e.Duration(now)
+		totalNet += e.NetDuration(now)
+	}
+	fmt.Printf("%s%s\nid      %s\ntasks   %d\nentries %d\ntotal   %s, net %s\n", c.Name, formatArchived(c.Archived), c.ID, len(tasks), len(entries),
+		formatDuration(total), formatDuration(totalNet))
+	for _, t := range tasks {
+		fmt.Printf("  %7s %7s  %s%s\n", formatDuration(spent[t.ID]), formatDuration(spentNet[t.ID]), taskName(t), formatArchived(t.Archived))
+	}
+	return nil
+}
+
+// task manages the tasks of a customer:
+//
+//	bac task add [-id <external id>] <customer> <name>
+//	bac task list [-all] <customer>
+//	bac task rename <customer> <task> <name>
+//	bac task archive [-undo] <customer> <task>
+//	bac task move <customer> <task> <to customer>
+//
+// Tasks are given by ID, external ID or name, archived ones are only listed with -all and
+// cannot be started. Moving a task takes its entries along.
+func task(args []string) error {
+	flags := flag.NewFlagSet("task", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	externalID := flags.String("id", "", "external ID of the task added, like PRJ-1234")
+	all := flags.Bool("all", false, "list archived tasks too")
+	undo := flags.Bool("undo", false, "bring an archived task back")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 2 {
+		return fmt.Errorf("want add, list, rename, archive or move followed by a <customer>")
+	}
+	command, positional := positional[0], positional[1:]
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	c, err := s.FindCustomer(positional[0])
+	if err != nil {
+		return err
+	}
+	switch command {
+	case "add":
+		if len(positional) != 2 {
+			return fmt.Errorf("want add [-id <external id>] <customer> <name>")
+		}
+		if _, err := s.FindTask(c.ID, positional[1]); !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("task %q of %s already exists", positional[1], c.Name)
+		}
+		ct, err := s.LoadTasks(c.ID)
+		if err != nil {
+			return err
+		}
+		t := &storage.Task{ID: uuid.New(), Customer: c, ExternalID: *externalID, Name: positional[1]}
+		if err := ct.AddTask(t); err != nil {
+			return err
+		}
+		if err := s.SaveTasks(ct); err != nil {
+			return err
+		}
+		fmt.Printf("added task %s %s\n", describeTask(t), t.ID)
+		return nil
+	case "list":
+		if len(positional) != 1 {
+			return fmt.Errorf("want list [-all] <customer>")
+		}
+		tasks, err := sortedTasks(s, c.ID)
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			if t.Archived && !*all {
+				continue
+			}
+			fmt.Printf("%s  %s%s\n", t.ID, taskName(t), formatArchived(t.Archived))
+		}
+		return nil
+	case "rename":
+		if len(positional) != 3 {
+			return fmt.Errorf("want rename <customer> <task> <name>")
+		}
+		t, err := s.FindTask(c.ID, positional[1])
+		if err != nil {
+			return err
+		}
+		if _, err := s.RenameTask(c.ID, t.ID, positional[2]); err != nil {
+			return err
+		}
+		fmt.Printf("renamed task %s to %s\n", describeTask(t), positional[2])
+		return nil
+	case "archive":
+		if len(positional) != 2 {
+			return fmt.Errorf("want archive [-undo] <customer> <task>")
+		}
+		t, err := s.FindTask(c.ID, positional[1])
+		if err != nil {
+			return err
+		}
+		if _, err := s.ArchiveTask(c.ID, t.ID, !*undo); err != nil {
+			return err
+		}
+		if *undo {
+			fmt.Printf("unarchived task %s\n", describeTask(t))
+		} else {
+			fmt.Printf("archived task %s\n", describeTask(t))
+		}
+		return nil
+	case "move":
+		if len(positional) != 3 {
+			return fmt.Errorf("want move <customer> <task> <to customer>")
+		}
+		t, err := s.FindTask(c.ID, positional[1])
+		if err != nil {
+			return err
+		}
+		to, err := s.FindCustomer(positional[2])
+		if err != nil {
+			return err
+		}
+		moved, err := s.MoveTask(c.ID, t.ID, to.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("moved task %s to %s\n", describeTask(t), describeTask(moved))
+		return nil
+	default:
+		return fmt.Errorf("unknown task command %q", command)
+	}
+}
+
+// sortedTasks returns the tasks of a customer sorted by name.
+func sortedTasks(s *storage.Store, customerID uuid.UUID) ([]*storage.Task, error) {
+	ct, err := s.LoadTasks(customerID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ct.Tasks, func(i, j int) bool {
+		return ct.Tasks[i].Name < ct.Tasks[j].Name
+	})
+	return ct.Tasks, nil
+}
+
+// formatArchived flags archived customers and tasks in listings.
+func formatArchived(archived bool) string {
+	if archived {
+		return "  (archived)"
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"ballandchain/storage"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// errConflicts makes git leave a merged file as conflicted.
+var errConflicts = errors.New("conflicts need a decision")
+
+// mergeDriver merges a data file the way the git merge driver protocol expects: the versions
+// are read from the base, ours and theirs files and the result is written over ours. path is
+// the file relative to the data root. Conflicts keep the local value and fail the merge so
+// git stops and lets them be reviewed.
+func mergeDriver(args []string) error {
+	if len(args) != 4 {
+		return fmt.Errorf("want 4 arguments, got %d: <base> <ours> <theirs> <path>", len(args))
+	}
+	basePath, oursPath, theirsPath, path := args[0], args[1], args[2], args[3]
+	var versions [3][]byte
+	for i, p := range []string{basePath, oursPath, theirsPath} {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", p, err)
+		}
+		versions[i] = content
+	}
+	// git passes an empty base when both sides added the file
+	if len(versions[0]) == 0 {
+		versions[0] = nil
+	}
+	result, err := storage.MergeDataFile(filepath.ToSlash(path), versions[0], versions[1], versions[2])
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(oursPath, result.Content, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", oursPath, err)
+	}
+	for _, resolved := range result.Resolved {
+		fmt.Fprintln(os.Stderr, "resolved", resolved)
+	}
+	for _, conflict := range result.Conflicts {
+		fmt.Fprintln(os.Stderr, "conflict", conflict)
+	}
+	if len(result.Conflicts) > 0 {
+		return errConflicts
+	}
+	return nil
+}
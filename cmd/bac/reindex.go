@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// reindex rebuilds the search indexes of a data root, they are normally rebuilt on their own
+// whenever the data changes behind them:
+//
+//	bac reindex
+func reindex(args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("want no arguments")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if s.IndexesInMemory() {
+		return fmt.Errorf("the indexes of %s are in use by another process", s.Root())
+	}
+	return s.Reindex()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"ballandchain/storage"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// key manages the key signing the timesheets of a data root:
+//
+//	bac key generate   create the key, once
+//	bac key rotate     replace the key, the previous public key is kept
+//	bac key show       print the fingerprint and public key to hand to customers
+func key(args []string) error {
+	flags := flag.NewFlagSet("key", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("want generate, rotate or show")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	switch flags.Arg(0) {
+	case "generate":
+		fingerprint, err := s.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(fingerprint)
+	case "rotate":
+		fingerprint, err := s.RotateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(fingerprint)
+	case "show":
+		public, err := s.SigningKey()
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return err
+		}
+		fmt.Println(storage.Fingerprint(public))
+		return pem.Encode(os.Stdout, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	default:
+		return fmt.Errorf("unknown key command %q", flags.Arg(0))
+	}
+	return nil
+}
+
+// export writes the signed timesheet of a customer between two dates, both included:
+//
+//	bac export [-o <file>] <customer> <from> <to>
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	out := flags.String("o", "", "file to write, defaults to stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 3 {
+		return fmt.Errorf("want <customer> <from> <to>")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	c, err := s.FindCustomer(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	from, to, err := parsePeriod(flags.Arg(1), flags.Arg(2), s.Location())
+	if err != nil {
+		return err
+	}
+	data, err := s.ExportTimesheet(c.ID, from, to)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0644)
+}
+
+// verify checks a signed timesheet, it needs no data root so customers can run it:
+//
+//	bac verify [-fingerprint <fingerprint>] <file>
+func verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fingerprint := flags.String("fingerprint", "", "fingerprint the signing key must have")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("want <file>")
+	}
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	ts, err := storage.VerifyTimesheet(data)
+	if err != nil {
+		return err
+	}
+	if *fingerprint != "" && *fingerprint != ts.Fingerprint {
+		return fmt.Errorf("signed by %s, not by %s", ts.Fingerprint, *fingerprint)
+	}
+	fmt.Printf("%s from %s to %s: %d entries, %s\n", ts.Customer, ts.From.Format(time.DateOnly),
+		ts.To.AddDate(0, 0, -1).Format(time.DateOnly), len(ts.Entries), ts.Total)
+	fmt.Printf("signed by %s at chain record %d\n", ts.Fingerprint, ts.ChainSeq)
+	if *fingerprint == "" {
+		fmt.Println("compare the fingerprint with the one you were given, or pass it with -fingerprint")
+	}
+	return nil
+}
+
+// parsePeriod parses two YYYY-MM-DD dates in loc into the period from the start of the
+// first to the end of the second.
+func parsePeriod(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(time.DateOnly, from, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parsing from: %w", err)
+	}
+	end, err := time.ParseInLocation(time.DateOnly, to, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parsing to: %w", err)
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	tests := []struct {
+		name     string
+		from, to string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "month",
+			from:     "2024-05-01",
+			to:       "2024-05-31",
+			wantFrom: time.Date(2024, 5, 1, 0, 0, 0, 0, loc),
+			wantTo:   time.Date(2024, 6, 1, 0, 0, 0, 0, loc),
+		},
+		{
+			name:     "single day",
+			from:     "2024-05-02",
+			to:       "2024-05-02",
+			wantFrom: time.Date(2024, 5, 2, 0, 0, 0, 0, loc),
+			wantTo:   time.Date(2024, 5, 3, 0, 0, 0, 0, loc),
+		},
+		{
+			name:    "bad from",
+			from:    "05/01/2024",
+			to:      "2024-05-31",
+			wantErr: true,
+		},
+		{
+			name:    "bad to",
+			from:    "2024-05-01",
+			to:      "end of month",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := parsePeriod(tt.from, tt.to, loc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePeriod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("parsePeriod() = %v, %v, want %v, %v", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
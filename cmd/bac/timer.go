@@ -0,0 +1,493 @@
+package main
+
+import (
+	"ballandchain/storage"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// errNotRunning is returned when stopping without a timer running.
+var errNotRunning = errors.New("no timer running")
+
+// errNotPaused is returned when resuming without a timer paused.
+var errNotPaused = errors.New("no timer paused")
+
+// errAlreadyPaused is returned when pausing with every running timer paused already.
+var errAlreadyPaused = errors.New("timer already paused")
+
+// entryTimeLayouts are the ways a time can be given to add, in the store timezone.
+var entryTimeLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "15:04"}
+
+// start starts a timer on a task, the running one is stopped unless parallel_timers is set in
+// the root config:
+//
+//	bac start [-m <comment>] <customer> <task>
+func start(args []string) error {
+	flags := flag.NewFlagSet("start", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	comment := flags.String("m", "", "comment of the entry")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("want <customer> <task>")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return startEntry(s, positional[0], positional[1], *comment)
+}
+
+// stop finishes the running timers, only those of a customer if one is given:
+//
+//	bac stop [<customer>]
+func stop(args []string) error {
+	flags := flag.NewFlagSet("stop", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("want at most a <customer>")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	customer := ""
+	if len(positional) == 1 {
+		customer = positional[0]
+	}
+	stopped, err := stopEntries(s, customer)
+	if err != nil {
+		return err
+	}
+	if stopped == 0 {
+		return errNotRunning
+	}
+	return nil
+}
+
+// switchTask stops the running timers and starts one on another task:
+//
+//	bac switch [-m <comment>] <customer> <task>
+func switchTask(args []string) error {
+	flags := flag.NewFlagSet("switch", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	comment := flags.String("m", "", "comment of the entry")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("want <customer> <task>")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	// resolve the task before stopping anything so a typo leaves the timer running
+	t, err := findTask(s, positional[0], positional[1])
+	if err != nil {
+		return err
+	}
+	if err := checkActive(t); err != nil {
+		return err
+	}
+	if _, err := stopEntries(s, ""); err != nil {
+		return err
+	}
+	return startEntry(s, positional[0], positional[1], *comment)
+}
+
+// status prints the running timers:
+//
+//	bac status
+func status(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return fmt.Errorf("want no arguments")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	running, err := s.Status()
+	if err != nil {
+		return err
+	}
+	if len(running) == 0 {
+		fmt.Println(errNotRunning)
+		return nil
+	}
+	now := time.Now()
+	for _, e := range running {
+		paused := ""
+		if e.Paused() {
+			paused = ", paused since " + formatStart(e.Breaks[len(e.Breaks)-1].Start.In(s.Location()), now.In(s.Location()))
+		}
+		fmt.Printf("%s since %s (%s%s)%s\n", describeTask(e.Task), formatStart(e.StartTS.In(s.Location()), now.In(s.Location())),
+			formatDurations(e, now), paused, formatComment(e.Comment))
+	}
+	return nil
+}
+
+// pause pauses the running timers, only those of a customer if one is given:
+//
+//	bac pause [<customer>]
+func pause(args []string) error {
+	return pauseOrResume("pause", args, func(s *storage.Store, e *storage.Entry) (bool, error) {
+		if e.Paused() {
+			return false, nil
+		}
+		return true, s.PauseEntry(e, time.Now())
+	})
+}
+
+// resume resumes the paused timers, only those of a customer if one is given:
+//
+//	bac resume [<customer>]
+func resume(args []string) error {
+	return pauseOrResume("resume", args, func(s *storage.Store, e *storage.Entry) (bool, error) {
+		if !e.Paused() {
+			return false, nil
+		}
+		return true, s.ResumeEntry(e, time.Now())
+	})
+}
+
+// pauseOrResume applies fn to the running timers of the customer given in args, or of every
+// customer, fn tells if the timer was changed.
+func pauseOrResume(name string, args []string, fn func(s *storage.Store, e *storage.Entry) (bool, error)) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("want at most a <customer>")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	customer := ""
+	if len(positional) == 1 {
+		customer = positional[0]
+	}
+	running, err := runningEntries(s, customer)
+	if err != nil {
+		return err
+	}
+	changed := 0
+	for _, e := range running {
+		ok, err := fn(s, e)
+		if err != nil {
+			return err
+		}
+		if ok {
+			fmt.Printf("%sd %s after %s\n", name, describeTask(e.Task), formatDurations(e, time.Now()))
+			changed++
+		}
+	}
+	switch {
+	case len(running) == 0:
+		return errNotRunning
+	case changed == 0 && name == "pause":
+		return errAlreadyPaused
+	case changed == 0:
+		return errNotPaused
+	}
+	return nil
+}
+
+// logEntries lists the entries of a period, the last week by default:
+//
+//	bac log [-from <date>] [-to <date>] [<customer>]
+func logEntries(args []string) error {
+	flags := flag.NewFlagSet("log", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	fromFlag := flags.String("from", "", "first day listed as YYYY-MM-DD, defaults to a week ago")
+	toFlag := flags.String("to", "", "last day listed as YYYY-MM-DD, defaults to today")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("want at most a <customer>")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	loc := s.Location()
+	today := time.Now().In(loc).Format(time.DateOnly)
+	if *toFlag == "" {
+		*toFlag = today
+	}
+	if *fromFlag == "" {
+		t, _ := time.ParseInLocation(time.DateOnly, today, loc)
+		*fromFlag = t.AddDate(0, 0, -6).Format(time.DateOnly)
+	}
+	from, to, err := parsePeriod(*fromFlag, *toFlag, loc)
+	if err != nil {
+		return err
+	}
+	filter := storage.EntryFilter{From: from, To: to}
+	if len(positional) == 1 {
+		c, err := s.FindCustomer(positional[0])
+		if err != nil {
+			return err
+		}
+		filter.CustomerID = c.ID
+	}
+	entries, err := s.LoadEntries(filter)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var total, totalNet time.Duration
+	day := ""
+	for _, e := range entries {
+		start := e.StartTS.In(loc)
+		if d := start.Format("Mon 2006-01-02"); d != day {
+			day = d
+			fmt.Println(day)
+		}
+		until := "     "
+		if e.EndTs != nil {
+			until = e.EndTs.In(loc).Format("15:04")
+		}
+		total += e.Duration(now)
+		totalNet += e.NetDuration(now)
+		fmt.Printf("  %s-%s %7s %7s  %s%s\n", start.Format("15:04"), until, formatDuration(e.Duration(now)), formatDuration(e.NetDuration(now)),
+			describeTask(e.Task), formatComment(e.Comment))
+	}
+	fmt.Printf("total %s, net %s\n", formatDuration(total), formatDuration(totalNet))
+	return nil
+}
+
+// add records an entry after the fact, it is split at midnight like timers are:
+//
+//	bac add -from <time> -to <time> [-m <comment>] <customer> <task>
+func add(args []string) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	root := flags.String("root", "", "data root, defaults to BAC_ROOT_FOLDER or ~/.ballandchain")
+	fromFlag := flags.String("from", "", "start as YYYY-MM-DD HH:MM, or HH:MM for today")
+	toFlag := flags.String("to", "", "end as YYYY-MM-DD HH:MM, or HH:MM for the day it started or the next")
+	comment := flags.String("m", "", "comment of the entry")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 || *fromFlag == "" || *toFlag == "" {
+		return fmt.Errorf("want -from <time> -to <time> <customer> <task>")
+	}
+	s, err := openStore(*root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	task, err := findTask(s, positional[0], positional[1])
+	if err != nil {
+		return err
+	}
+	loc := s.Location()
+	from, err := parseEntryTime(*fromFlag, time.Now().In(loc))
+	if err != nil {
+		return fmt.Errorf("parsing from: %w", err)
+	}
+	to, err := parseEntryTime(*toFlag, from)
+	if err != nil {
+		return fmt.Errorf("parsing to: %w", err)
+	}
+	if !to.After(from) && !strings.Contains(*toFlag, "-") {
+		// a time of day before the start is on the next day
+		to = to.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return fmt.Errorf("%s is not after %s", *toFlag, *fromFlag)
+	}
+	e := storage.NewEntry(task, from)
+	e.Comment = *comment
+	if err := s.FinishEntryAt(e, to); err != nil {
+		return err
+	}
+	fmt.Printf("added %s %s-%s (%s)\n", describeTask(task), from.Format("2006-01-02 15:04"), to.Format("15:04"), formatDuration(to.Sub(from)))
+	return nil
+}
+
+// startEntry starts a timer on a task of a customer, both given by name, ID or external ID.
+func startEntry(s *storage.Store, customer, task, comment string) error {
+	t, err := findTask(s, customer, task)
+	if err != nil {
+		return err
+	}
+	if err := checkActive(t); err != nil {
+		return err
+	}
+	e := storage.NewEntry(t, time.Now())
+	e.Comment = comment
+	// durations are taken while running, once finished across midnight an entry only holds its first day
+	running, err := s.Status()
+	if err != nil {
+		return err
+	}
+	durations := make(map[uuid.UUID]string, len(running))
+	for _, r := range running {
+		durations[r.ID] = formatDurations(r, e.StartTS)
+	}
+	finished, err := s.Start(e)
+	if err != nil {
+		return err
+	}
+	for _, f := range finished {
+		fmt.Printf("stopped %s after %s\n", describeTask(f.Task), durations[f.ID])
+	}
+	fmt.Printf("started %s at %s\n", describeTask(t), e.StartTS.In(s.Location()).Format("15:04"))
+	return nil
+}
+
+// stopEntries finishes the running timers of a customer, of every customer if empty, and
+// returns how many there were.
+func stopEntries(s *storage.Store, customer string) (int, error) {
+	running, err := runningEntries(s, customer)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range running {
+		// taken while running, see startEntry
+		now := time.Now()
+		durations := formatDurations(e, now)
+		if err := s.FinishEntryAt(e, now); err != nil {
+			return 0, err
+		}
+		fmt.Printf("stopped %s after %s\n", describeTask(e.Task), durations)
+	}
+	return len(running), nil
+}
+
+// runningEntries returns the running timers of a customer, of every customer if empty.
+func runningEntries(s *storage.Store, customer string) ([]*storage.Entry, error) {
+	running, err := s.Status()
+	if err != nil || customer == "" {
+		return running, err
+	}
+	c, err := s.FindCustomer(customer)
+	if err != nil {
+		return nil, err
+	}
+	var ofCustomer []*storage.Entry
+	for _, e := range running {
+		if e.Task.Customer.ID == c.ID {
+			ofCustomer = append(ofCustomer, e)
+		}
+	}
+	return ofCustomer, nil
+}
+
+// findTask resolves a customer and one of its tasks given by name, ID or external ID.
+func findTask(s *storage.Store, customer, task string) (*storage.Task, error) {
+	c, err := s.FindCustomer(customer)
+	if err != nil {
+		return nil, err
+	}
+	return s.FindTask(c.ID, task)
+}
+
+// checkActive refuses archived tasks and the tasks of archived customers, they are done with.
+func checkActive(t *storage.Task) error {
+	switch {
+	case t.Customer != nil && t.Customer.Archived:
+		return fmt.Errorf("customer %s is archived", t.Customer.Name)
+	case t.Archived:
+		return fmt.Errorf("task %s is archived", describeTask(t))
+	}
+	return nil
+}
+
+// parseEntryTime parses a time given to add, a time of day alone is taken on the day of ref.
+func parseEntryTime(value string, ref time.Time) (time.Time, error) {
+	var err error
+	for _, layout := range entryTimeLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, ref.Location()); err != nil {
+			continue
+		}
+		if layout == "15:04" {
+			t = time.Date(ref.Year(), ref.Month(), ref.Day(), t.Hour(), t.Minute(), 0, 0, ref.Location())
+		}
+		return t, nil
+	}
+	return time.Time{}, err
+}
+
+// describeTask names a task along with its customer and external ID.
+func describeTask(t *storage.Task) string {
+	if t.Customer != nil {
+		return t.Customer.Name + " / " + taskName(t)
+	}
+	return taskName(t)
+}
+
+// taskName names a task along with its external ID.
+func taskName(t *storage.Task) string {
+	if t.ExternalID != "" {
+		return t.ExternalID + " " + t.Name
+	}
+	return t.Name
+}
+
+// formatStart prints when something started, with the date unless it was today.
+func formatStart(start, now time.Time) string {
+	if start.Format(time.DateOnly) == now.Format(time.DateOnly) {
+		return start.Format("15:04")
+	}
+	return start.Format("2006-01-02 15:04")
+}
+
+// formatDuration prints a duration to the minute, like 1h05m or 45m.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// formatDurations prints the duration of an entry up to now, followed by its net duration if
+// it had breaks.
+func formatDurations(e *storage.Entry, now time.Time) string {
+	if len(e.Breaks) == 0 {
+		return formatDuration(e.Duration(now))
+	}
+	return fmt.Sprintf("%s, net %s", formatDuration(e.Duration(now)), formatDuration(e.NetDuration(now)))
+}
+
+// formatComment prints the first line of a comment after what it belongs to.
+func formatComment(comment string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(comment), "\n")
+	if first == "" {
+		return ""
+	}
+	return "  " + first
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEntryTime(t *testing.T) {
+	loc := time.FixedZone("UTC-3", -3*60*60)
+	ref := time.Date(2024, 5, 2, 18, 30, 0, 0, loc)
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:  "date and time",
+			value: "2024-04-30 09:15",
+			want:  time.Date(2024, 4, 30, 9, 15, 0, 0, loc),
+		},
+		{
+			name:  "date and time with T",
+			value: "2024-04-30T09:15",
+			want:  time.Date(2024, 4, 30, 9, 15, 0, 0, loc),
+		},
+		{
+			name:  "time of day on the reference day",
+			value: "09:15",
+			want:  time.Date(2024, 5, 2, 9, 15, 0, 0, loc),
+		},
+		{
+			name:    "date alone",
+			value:   "2024-04-30",
+			wantErr: true,
+		},
+		{
+			name:    "garbage",
+			value:   "soon",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEntryTime(tt.value, ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEntryTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseEntryTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 45 * time.Minute, want: "45m"},
+		{d: 59*time.Minute + 40*time.Second, want: "1h00m"},
+		{d: time.Hour + 5*time.Minute, want: "1h05m"},
+		{d: 26 * time.Hour, want: "26h00m"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package idle
+
+import (
+	"context"
+	"fmt"
+	"github.com/godbus/dbus/v5"
+	"time"
+)
+
+// screenSavers are the session bus interfaces sending ActiveChanged when the screensaver of
+// the desktop starts or stops.
+var screenSavers = []string{"org.freedesktop.ScreenSaver", "org.gnome.ScreenSaver"}
+
+// DBus is the Source of the screensaver on the session bus, the user is idle while it is active.
+type DBus struct{}
+
+// Events implements Source, leaving is dated back by the idle time of the session when the
+// screensaver tells it.
+func (DBus) Events(ctx context.Context) (<-chan Event, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the session bus: %w", err)
+	}
+	for _, iface := range screenSavers {
+		if err := conn.AddMatchSignal(dbus.WithMatchInterface(iface), dbus.WithMatchMember("ActiveChanged")); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("watching %s: %w", iface, err)
+		}
+	}
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer conn.Close()
+		for {
+			var sig *dbus.Signal
+			select {
+			case sig = <-signals:
+			case <-ctx.Done():
+				return
+			}
+			if sig == nil {
+				// the connection was lost
+				return
+			}
+			active, ok := activeChanged(sig)
+			if !ok {
+				continue
+			}
+			ev := Event{Idle: active, At: time.Now()}
+			if active {
+				ev.At = ev.At.Add(-sessionIdleTime(conn))
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// activeChanged returns the state sent by an ActiveChanged signal of a screensaver.
+func activeChanged(sig *dbus.Signal) (bool, bool) {
+	for _, iface := range screenSavers {
+		if sig.Name == iface+".ActiveChanged" && len(sig.Body) == 1 {
+			active, ok := sig.Body[0].(bool)
+			return active, ok
+		}
+	}
+	return false, false
+}
+
+// sessionIdleTime returns how long the session has been idle, 0 if the screensaver cannot tell.
+func sessionIdleTime(conn *dbus.Conn) time.Duration {
+	var seconds uint32
+	err := conn.Object("org.freedesktop.ScreenSaver", "/org/freedesktop/ScreenSaver").
+		Call("org.freedesktop.ScreenSaver.GetSessionIdleTime", 0).Store(&seconds)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
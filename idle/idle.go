@@ -0,0 +1,102 @@
+// Package idle notices the machine being left alone while a timer runs and has the time spent
+// away kept, discarded or booked to another task on return.
+package idle
+
+import (
+	"ballandchain/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultMinIdle is how long the user has to be away before Monitor asks about it.
+const DefaultMinIdle = 5 * time.Minute
+
+// Event is the user leaving or coming back, as reported by a Source.
+type Event struct {
+	Idle bool      // true when the user left, false when they came back
+	At   time.Time // when it happened, leaving is usually noticed some time after
+}
+
+// Source reports the user leaving and coming back, see DBus.
+type Source interface {
+	// Events sends the changes as they happen until ctx is done, the channel is closed then.
+	Events(ctx context.Context) (<-chan Event, error)
+}
+
+// Decision is what to do with the time a running entry spent idle.
+type Decision struct {
+	Action storage.IdleAction
+	Task   *storage.Task // the task ReassignIdle books the time to
+}
+
+// Monitor watches a Source and, when the user comes back, asks what to do with the time the
+// running entries spent idle and splits them to match.
+type Monitor struct {
+	Store  *storage.Store
+	Source Source
+	// MinIdle is the shortest time away asked about, shorter ones are kept. It defaults to
+	// DefaultMinIdle.
+	MinIdle time.Duration
+	// Ask decides what to do with the time e spent idle between from and to, an error stops Run.
+	Ask func(e *storage.Entry, from, to time.Time) (Decision, error)
+}
+
+// Run watches until ctx is done, which is not an error, or the first error.
+func (m *Monitor) Run(ctx context.Context) error {
+	events, err := m.Source.Events(ctx)
+	if err != nil {
+		return fmt.Errorf("watching idleness: %w", err)
+	}
+	var since time.Time // zero while the user is around
+	for ev := range events {
+		if ev.Idle {
+			if since.IsZero() {
+				since = ev.At
+			}
+			continue
+		}
+		if since.IsZero() {
+			continue
+		}
+		from := since
+		since = time.Time{}
+		if err := m.resolve(from, ev.At); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve asks about the time the running entries spent idle between from and to. Paused
+// entries are left alone, nobody was working on them anyway.
+func (m *Monitor) resolve(from, to time.Time) error {
+	minIdle := m.MinIdle
+	if minIdle == 0 {
+		minIdle = DefaultMinIdle
+	}
+	if to.Sub(from) < minIdle {
+		return nil
+	}
+	running, err := m.Store.Status()
+	if err != nil {
+		return err
+	}
+	for _, e := range running {
+		if e.Paused() || !e.StartTS.Before(to) {
+			continue
+		}
+		start := from
+		if start.Before(e.StartTS) {
+			start = e.StartTS
+		}
+		d, err := m.Ask(e, start, to)
+		if err != nil {
+			return err
+		}
+		if _, err := m.Store.ResolveIdle(e, start, to, d.Action, d.Task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package idle
+
+import (
+	"ballandchain/storage"
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// fakeSource sends the events it was given and stops.
+type fakeSource []Event
+
+// Events implements Source.
+func (f fakeSource) Events(ctx context.Context) (<-chan Event, error) {
+	events := make(chan Event, len(f))
+	for _, ev := range f {
+		events <- ev
+	}
+	close(events)
+	return events, nil
+}
+
+// chanSource sends the events given on it until it is closed.
+type chanSource chan Event
+
+// Events implements Source.
+func (c chanSource) Events(ctx context.Context) (<-chan Event, error) {
+	return c, nil
+}
+
+// span is an entry as checked by the tests, a zero end means still running.
+type span struct {
+	task       string
+	start, end time.Time
+}
+
+func TestMonitor_Run(t *testing.T) {
+	start := time.Date(2024, 1, 2, 17, 0, 0, 0, time.UTC)
+	left, back := start.Add(30*time.Minute), start.Add(16*time.Hour)
+	midnight := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		events    fakeSource
+		decision  Decision
+		reassign  bool
+		wantAsked bool
+		want      []span
+	}{
+		{
+			name:      "keep",
+			events:    fakeSource{{Idle: true, At: left}, {Idle: false, At: back}},
+			decision:  Decision{Action: storage.KeepIdle},
+			wantAsked: true,
+			want:      []span{{"Work", start, time.Time{}}},
+		},
+		{
+			name:      "discard",
+			events:    fakeSource{{Idle: true, At: left}, {Idle: false, At: back}},
+			decision:  Decision{Action: storage.DiscardIdle},
+			wantAsked: true,
+			want:      []span{{"Work", start, left}, {"Work", back, time.Time{}}},
+		},
+		{
+			name:      "reassign",
+			events:    fakeSource{{Idle: true, At: left}, {Idle: false, At: back}},
+			decision:  Decision{Action: storage.ReassignIdle},
+			reassign:  true,
+			wantAsked: true,
+			want:      []span{{"Work", start, left}, {"Meeting", left, midnight}, {"Meeting", midnight, back}, {"Work", back, time.Time{}}},
+		},
+		{
+			name:   "too short",
+			events: fakeSource{{Idle: true, At: left}, {Idle: false, At: left.Add(time.Minute)}},
+			want:   []span{{"Work", start, time.Time{}}},
+		},
+		{
+			name:   "never back",
+			events: fakeSource{{Idle: true, At: left}},
+			want:   []span{{"Work", start, time.Time{}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := storage.Open(t.TempDir(), storage.Options{Location: time.UTC})
+			if err != nil {
+				t.Fatalf("storage.Open() error = %v", err)
+			}
+			defer s.Close()
+			customer := storage.NewCustomer("Test Customer")
+			if err := s.AddCustomer(customer); err != nil {
+				t.Fatalf("Store.AddCustomer() error = %v", err)
+			}
+			ct, err := s.LoadTasks(customer.ID)
+			if err != nil {
+				t.Fatalf("Store.LoadTasks() error = %v", err)
+			}
+			work := &storage.Task{ID: uuid.New(), Customer: customer, Name: "Work"}
+			meeting := &storage.Task{ID: uuid.New(), Customer: customer, Name: "Meeting"}
+			for _, task := range []*storage.Task{work, meeting} {
+				if err := ct.AddTask(task); err != nil {
+					t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+				}
+			}
+			if err := s.SaveTasks(ct); err != nil {
+				t.Fatalf("Store.SaveTasks() error = %v", err)
+			}
+			if _, err := s.Start(storage.NewEntry(work, start)); err != nil {
+				t.Fatalf("Store.Start() error = %v", err)
+			}
+
+			asked := false
+			m := &Monitor{Store: s, Source: tt.events, Ask: func(e *storage.Entry, from, to time.Time) (Decision, error) {
+				asked = true
+				if !from.Equal(left) || !to.Equal(back) {
+					t.Errorf("Ask() idle from %s to %s, want from %s to %s", from, to, left, back)
+				}
+				d := tt.decision
+				if tt.reassign {
+					d.Task = meeting
+				}
+				return d, nil
+			}}
+			if err := m.Run(context.Background()); err != nil {
+				t.Fatalf("Monitor.Run() error = %v", err)
+			}
+			if asked != tt.wantAsked {
+				t.Errorf("Monitor.Run() asked = %v, want %v", asked, tt.wantAsked)
+			}
+
+			entries, err := s.LoadEntries(storage.EntryFilter{CustomerID: customer.ID})
+			if err != nil {
+				t.Fatalf("Store.LoadEntries() error = %v", err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("Store.LoadEntries() = %d entries, want %d", len(entries), len(tt.want))
+			}
+			for i, e := range entries {
+				var end time.Time
+				if e.EndTs != nil {
+					end = *e.EndTs
+				}
+				got := span{e.Task.Name, e.StartTS, end}
+				if got.task != tt.want[i].task || !got.start.Equal(tt.want[i].start) || !got.end.Equal(tt.want[i].end) {
+					t.Errorf("entry %d = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMonitor_RunOutsideChanges(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	left, back := start.Add(time.Hour), start.Add(2*time.Hour)
+	root := t.TempDir()
+	s, err := storage.Open(root, storage.Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	defer s.Close()
+	events := make(chanSource)
+	var asked *storage.Entry
+	m := &Monitor{Store: s, Source: events, Ask: func(e *storage.Entry, from, to time.Time) (Decision, error) {
+		asked = e
+		return Decision{Action: storage.DiscardIdle}, nil
+	}}
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(context.Background())
+	}()
+	events <- Event{Idle: true, At: left}
+
+	// another bac command adds a customer and task the monitor has not seen and starts a timer on it
+	other, err := storage.Open(root, storage.Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	customer := storage.NewCustomer("Test Customer")
+	if err := other.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := other.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	work := &storage.Task{ID: uuid.New(), Customer: customer, Name: "Work"}
+	if err := ct.AddTask(work); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := other.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	if _, err := other.Start(storage.NewEntry(work, start)); err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	if err := other.Close(); err != nil {
+		t.Fatalf("Store.Close() error = %v", err)
+	}
+
+	events <- Event{Idle: false, At: back}
+	close(events)
+	if err := <-done; err != nil {
+		t.Fatalf("Monitor.Run() error = %v", err)
+	}
+	if asked == nil || asked.Task == nil || asked.Task.ID != work.ID {
+		t.Fatalf("Monitor.Run() asked about %v, want the entry of the new task", asked)
+	}
+	running, err := s.Status()
+	if err != nil {
+		t.Fatalf("Store.Status() error = %v", err)
+	}
+	if len(running) != 1 || !running[0].StartTS.Equal(back) {
+		t.Errorf("Store.Status() = %v, want the entry running again from %s", running, back)
+	}
+}
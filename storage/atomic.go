@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// tempSuffix marks the files writeFileAtomic writes to before renaming them into place.
+const tempSuffix = ".tmp"
+
+// writeFileAtomic writes path through a synced temporary file in the same folder which is then
+// renamed over path, readers either see the previous content or the new one, never a partial write.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	return writeFileAtomicMode(path, 0644, write)
+}
+
+// writeFileAtomicMode is writeFileAtomic giving the file the permissions perm.
+func writeFileAtomicMode(path string, perm fs.FileMode, write func(w io.Writer) error) (err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*"+tempSuffix)
+	if err != nil {
+		return fmt.Errorf("create temporary file for %s: %w", path, err)
+	}
+	tmpPath := f.Name()
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("sync temporary file for %s: %w", path, err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("close temporary file for %s: %w", path, err)
+	}
+	if err = os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("set permissions of temporary file for %s: %w", path, err)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename temporary file into %s: %w", path, err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes a folder so a rename within it survives a crash, windows does not support it.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open directory %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// isTempFile tells if name looks like a file left behind by an interrupted writeFileAtomic.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempSuffix)
+}
+
+// RecoverTempFiles removes the temporary files interrupted writes left under root and returns
+// their paths, the files they were meant to replace still hold their previous content.
+func RecoverTempFiles(root string) ([]string, error) {
+	unlock, err := lockRoot(root, true, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var leftovers []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isTempFile(d.Name()) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing leftover temporary file %s: %w", path, err)
+		}
+		leftovers = append(leftovers, path)
+		return nil
+	})
+	if err != nil {
+		return leftovers, fmt.Errorf("looking for leftover temporary files: %w", err)
+	}
+	return leftovers, nil
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tmpFolder := t.TempDir()
+	path := filepath.Join(tmpFolder, "tasks.json")
+	if err := os.WriteFile(path, []byte("previous"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	tests := []struct {
+		name    string
+		write   func(w io.Writer) error
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "failed write keeps previous content",
+			write: func(w io.Writer) error {
+				io.WriteString(w, "partial")
+				return errors.New("disk full")
+			},
+			want:    "previous",
+			wantErr: true,
+		},
+		{
+			name: "successful write replaces content",
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "new")
+				return err
+			},
+			want: "new",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeFileAtomic(path, tt.write); (err != nil) != tt.wantErr {
+				t.Errorf("writeFileAtomic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("writeFileAtomic() content = %q, want %q", got, tt.want)
+			}
+			// no temporary file must be left behind either way
+			leftovers, err := RecoverTempFiles(tmpFolder)
+			if err != nil || len(leftovers) != 0 {
+				t.Errorf("RecoverTempFiles() = %v, %v, want no leftovers", leftovers, err)
+			}
+		})
+	}
+}
+
+func TestOpen_RecoversTempFiles(t *testing.T) {
+	tmpFolder := t.TempDir()
+	customer := NewCustomer("Test Customer")
+	if err := customer.save(tmpFolder); err != nil {
+		t.Fatalf("Failed to save customer: %v", err)
+	}
+	leftover := filepath.Join(customer.SavePath(tmpFolder), ".tasks.json.123456"+tempSuffix)
+	if err := os.WriteFile(leftover, []byte(`[{"id": "trunc`), 0644); err != nil {
+		t.Fatalf("Failed to write leftover: %v", err)
+	}
+	s, err := Open(tmpFolder, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	if got := s.Leftovers(); len(got) != 1 || got[0] != leftover {
+		t.Errorf("Store.Leftovers() = %v, want [%s]", got, leftover)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("leftover %s was not removed", leftover)
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Backend persists customers, tasks and entries, the Store keeps its caches and indexes on top of it.
+//
+// Tasks returned by a backend point to the customer they were loaded for. Entries returned by a
+// backend only carry the reference to their task, the Store resolves it against its caches.
+// Zero from or to times mean the range is unbounded on that side.
+type Backend interface {
+	// SaveCustomer creates or updates the customer metadata.
+	SaveCustomer(c *Customer) error
+	// LoadCustomer returns the customer with the given ID or ErrNotFound.
+	LoadCustomer(id uuid.UUID) (*Customer, error)
+	// LoadCustomers returns all the customers.
+	LoadCustomers() ([]Customer, error)
+	// DeleteCustomer removes a customer along with its tasks and entries.
+	DeleteCustomer(id uuid.UUID) error
+
+	// SaveTasks replaces all the tasks of a customer.
+	SaveTasks(ct *CustomerTasks) error
+	// LoadTasks returns the tasks of a customer, an empty list if it has none.
+	LoadTasks(c *Customer) (*CustomerTasks, error)
+
+	// SaveEntry creates or updates an entry.
+	SaveEntry(e *Entry) error
+	// SaveEntries creates or updates several entries, atomically if the backend is transactional.
+	SaveEntries(entries ...*Entry) error
+	// DeleteEntry removes an entry.
+	DeleteEntry(e *Entry) error
+	// LoadEntries returns the entries of a customer started within [from, to) sorted by start date.
+	LoadEntries(customerID uuid.UUID, from, to time.Time) ([]*Entry, error)
+	// WalkEntries calls fn for the entries of a customer started within [from, to) in chronological
+	// order, loading them lazily so walking the whole history takes constant memory. The walk stops
+	// at the first error returned by fn or when ctx is done, fn returning ErrStopWalk is not an error.
+	WalkEntries(ctx context.Context, customerID uuid.UUID, from, to time.Time, fn func(e *Entry) error) error
+	// LastEntry returns the most recently started entry of a customer or ErrNotFound.
+	LastEntry(customerID uuid.UUID) (*Entry, error)
+
+	// Close releases any resource held by the backend.
+	Close() error
+}
+
+// ErrStopWalk can be returned by a WalkEntries callback to stop the walk early without failing it.
+var ErrStopWalk = errors.New("stop walking entries")
+
+// walkBatchSize is how many entries database backends read at a time while walking.
+const walkBatchSize = 256
+
+// walkResult turns the error ending a walk into the one WalkEntries returns.
+func walkResult(err error) error {
+	if errors.Is(err, ErrStopWalk) {
+		return nil
+	}
+	return err
+}
+
+// collectEntries walks the entries of a customer started within [from, to) into a slice.
+func collectEntries(b Backend, customerID uuid.UUID, from, to time.Time) ([]*Entry, error) {
+	var entries []*Entry
+	err := b.WalkEntries(context.Background(), customerID, from, to, func(e *Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// inRange tells if t is within [from, to), zero bounds are unbounded.
+func inRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+// Convert copies all customers, tasks and entries from src into dst, entries keep pointing to
+// the same tasks so converting back and forth is lossless.
+func Convert(dst, src Backend) error {
+	customers, err := src.LoadCustomers()
+	if err != nil {
+		return fmt.Errorf("loading customers: %w", err)
+	}
+	for _, customer := range customers {
+		customer := customer
+		if err := dst.SaveCustomer(&customer); err != nil {
+			return fmt.Errorf("saving customer %s: %w", customer.Name, err)
+		}
+		ct, err := src.LoadTasks(&customer)
+		if err != nil {
+			return fmt.Errorf("loading tasks for customer %s: %w", customer.Name, err)
+		}
+		if err := dst.SaveTasks(ct); err != nil {
+			return fmt.Errorf("saving tasks for customer %s: %w", customer.Name, err)
+		}
+		tasks := make(map[uuid.UUID]*Task, len(ct.Tasks))
+		for _, t := range ct.Tasks {
+			tasks[t.ID] = t
+		}
+		entries, err := src.LoadEntries(customer.ID, time.Time{}, time.Time{})
+		if err != nil {
+			return fmt.Errorf("loading entries for customer %s: %w", customer.Name, err)
+		}
+		for _, e := range entries {
+			task, ok := tasks[e.reference().TaskID]
+			if !ok {
+				return fmt.Errorf("entry %s points to unknown task %s", e.ID, e.reference().TaskID)
+			}
+			e.Task = task
+			if err := dst.SaveEntry(e); err != nil {
+				return fmt.Errorf("saving entry %s: %w", e.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,507 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testBackends lists every Backend implementation, all of them must pass the conformance suite.
+var testBackends = []struct {
+	name       string
+	newBackend func(t *testing.T) Backend
+	atomic     bool // SaveEntries saves all or nothing
+}{
+	{
+		name: "json",
+		newBackend: func(t *testing.T) Backend {
+			return NewJSONBackend(t.TempDir())
+		},
+	},
+	{
+		name: "sqlite",
+		newBackend: func(t *testing.T) Backend {
+			b, err := OpenSQLiteBackend(filepath.Join(t.TempDir(), sqliteFileName))
+			if err != nil {
+				t.Fatalf("OpenSQLiteBackend() error = %v", err)
+			}
+			return b
+		},
+		atomic: true,
+	},
+	{
+		name: "bolt",
+		newBackend: func(t *testing.T) Backend {
+			b, err := OpenBoltBackend(filepath.Join(t.TempDir(), boltFileName))
+			if err != nil {
+				t.Fatalf("OpenBoltBackend() error = %v", err)
+			}
+			return b
+		},
+		atomic: true,
+	},
+	{
+		name: "memory",
+		newBackend: func(t *testing.T) Backend {
+			return NewMemoryBackend()
+		},
+	},
+}
+
+func TestBackends(t *testing.T) {
+	for _, tb := range testBackends {
+		t.Run(tb.name, func(t *testing.T) {
+			t.Run("customers", func(t *testing.T) {
+				b := tb.newBackend(t)
+				defer b.Close()
+				testBackendCustomers(t, b)
+			})
+			t.Run("tasks", func(t *testing.T) {
+				b := tb.newBackend(t)
+				defer b.Close()
+				testBackendTasks(t, b)
+			})
+			t.Run("entries", func(t *testing.T) {
+				b := tb.newBackend(t)
+				defer b.Close()
+				testBackendEntries(t, b)
+			})
+			t.Run("walk entries", func(t *testing.T) {
+				b := tb.newBackend(t)
+				defer b.Close()
+				testBackendWalkEntries(t, b)
+			})
+			t.Run("save entries", func(t *testing.T) {
+				b := tb.newBackend(t)
+				defer b.Close()
+				testBackendSaveEntries(t, b, tb.atomic)
+			})
+			t.Run("store", func(t *testing.T) {
+				b := tb.newBackend(t)
+				testBackendStore(t, b)
+			})
+		})
+	}
+}
+
+func testBackendCustomers(t *testing.T, b Backend) {
+	customer1 := Customer{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"), Name: "Test Customer 1"}
+	customer2 := Customer{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174001"), Name: "Test Customer 2"}
+	for _, c := range []Customer{customer1, customer2} {
+		if err := b.SaveCustomer(&c); err != nil {
+			t.Fatalf("SaveCustomer() error = %v", err)
+		}
+	}
+	got, err := b.LoadCustomers()
+	if err != nil {
+		t.Fatalf("LoadCustomers() error = %v", err)
+	}
+	if !equalCustomers(got, []Customer{customer1, customer2}) {
+		t.Errorf("LoadCustomers() = %v, want %v", got, []Customer{customer1, customer2})
+	}
+
+	customer1.Name = "Renamed Customer"
+	if err := b.SaveCustomer(&customer1); err != nil {
+		t.Fatalf("SaveCustomer() error = %v", err)
+	}
+	c, err := b.LoadCustomer(customer1.ID)
+	if err != nil {
+		t.Fatalf("LoadCustomer() error = %v", err)
+	}
+	if *c != customer1 {
+		t.Errorf("LoadCustomer() = %v, want %v", c, customer1)
+	}
+
+	if err := b.DeleteCustomer(customer2.ID); err != nil {
+		t.Fatalf("DeleteCustomer() error = %v", err)
+	}
+	if _, err := b.LoadCustomer(customer2.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadCustomer() of deleted customer error = %v, want %v", err, ErrNotFound)
+	}
+	got, err = b.LoadCustomers()
+	if err != nil {
+		t.Fatalf("LoadCustomers() error = %v", err)
+	}
+	if !equalCustomers(got, []Customer{customer1}) {
+		t.Errorf("LoadCustomers() after delete = %v, want %v", got, []Customer{customer1})
+	}
+}
+
+func testBackendTasks(t *testing.T, b Backend) {
+	customer := &Customer{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"), Name: "Test Customer"}
+	if err := b.SaveCustomer(customer); err != nil {
+		t.Fatalf("SaveCustomer() error = %v", err)
+	}
+	ct, err := b.LoadTasks(customer)
+	if err != nil {
+		t.Fatalf("LoadTasks() error = %v", err)
+	}
+	if len(ct.Tasks) != 0 {
+		t.Errorf("LoadTasks() without tasks = %v, want none", ct.Tasks)
+	}
+
+	want := []*Task{
+		{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174100"), Customer: customer, ExternalID: "PRJ-1", Name: "First"},
+		{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174101"), Customer: customer, Name: "Second"},
+	}
+	ct.Tasks = want
+	if err := b.SaveTasks(ct); err != nil {
+		t.Fatalf("SaveTasks() error = %v", err)
+	}
+	ct, err = b.LoadTasks(customer)
+	if err != nil {
+		t.Fatalf("LoadTasks() error = %v", err)
+	}
+	if len(ct.Tasks) != len(want) {
+		t.Fatalf("LoadTasks() = %d tasks, want %d", len(ct.Tasks), len(want))
+	}
+	for i, got := range ct.Tasks {
+		if got.ID != want[i].ID || got.Name != want[i].Name || got.ExternalID != want[i].ExternalID {
+			t.Errorf("LoadTasks()[%d] = %v, want %v", i, got, want[i])
+		}
+		if got.Customer == nil || got.Customer.ID != customer.ID {
+			t.Errorf("LoadTasks()[%d] customer = %v, want %v", i, got.Customer, customer)
+		}
+	}
+}
+
+func testBackendEntries(t *testing.T, b Backend) {
+	customer := &Customer{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"), Name: "Test Customer"}
+	other := &Customer{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174001"), Name: "Other Customer"}
+	task := &Task{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174100"), Customer: customer, Name: "Task"}
+	otherTask := &Task{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174101"), Customer: other, Name: "Other Task"}
+	for _, task := range []*Task{task, otherTask} {
+		if err := b.SaveCustomer(task.Customer); err != nil {
+			t.Fatalf("SaveCustomer() error = %v", err)
+		}
+		if err := b.SaveTasks(&CustomerTasks{Customer: task.Customer, Tasks: []*Task{task}}); err != nil {
+			t.Fatalf("SaveTasks() error = %v", err)
+		}
+	}
+	if _, err := b.LastEntry(customer.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LastEntry() without entries error = %v, want %v", err, ErrNotFound)
+	}
+
+	base := time.Date(2024, 2, 28, 9, 0, 0, 0, time.UTC)
+	var entries []*Entry
+	for i := 0; i < 4; i++ {
+		e := NewEntry(task, base.AddDate(0, 0, i))
+		end := e.StartTS.Add(time.Hour)
+		e.EndTs = &end
+		e.Comment = "entry"
+		entries = append(entries, e)
+	}
+	// the last one is still running
+	entries[3].EndTs = nil
+	for _, e := range append(entries, NewEntry(otherTask, base)) {
+		if err := b.SaveEntry(e); err != nil {
+			t.Fatalf("SaveEntry() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want []*Entry
+	}{
+		{name: "unbounded", want: entries},
+		{name: "from", from: base.AddDate(0, 0, 2), want: entries[2:]},
+		{name: "to", to: base.AddDate(0, 0, 2), want: entries[:2]},
+		{name: "single day", from: base.AddDate(0, 0, 1).Add(-time.Hour), to: base.AddDate(0, 0, 1).Add(time.Hour), want: entries[1:2]},
+		{name: "empty", from: base.AddDate(1, 0, 0), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := b.LoadEntries(customer.ID, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("LoadEntries() error = %v", err)
+			}
+			assertEntries(t, got, tt.want)
+		})
+	}
+
+	last, err := b.LastEntry(customer.ID)
+	if err != nil {
+		t.Fatalf("LastEntry() error = %v", err)
+	}
+	assertEntries(t, []*Entry{last}, entries[3:])
+
+	end := entries[3].StartTS.Add(2 * time.Hour)
+	entries[3].EndTs = &end
+	if err := b.SaveEntry(entries[3]); err != nil {
+		t.Fatalf("SaveEntry() update error = %v", err)
+	}
+	if err := b.DeleteEntry(entries[0]); err != nil {
+		t.Fatalf("DeleteEntry() error = %v", err)
+	}
+	if err := b.DeleteEntry(entries[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteEntry() twice error = %v, want %v", err, ErrNotFound)
+	}
+	got, err := b.LoadEntries(customer.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("LoadEntries() error = %v", err)
+	}
+	assertEntries(t, got, entries[1:])
+}
+
+func testBackendWalkEntries(t *testing.T, b Backend) {
+	customer := &Customer{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"), Name: "Test Customer"}
+	task := &Task{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174100"), Customer: customer, Name: "Task"}
+	if err := b.SaveCustomer(customer); err != nil {
+		t.Fatalf("SaveCustomer() error = %v", err)
+	}
+	if err := b.SaveTasks(&CustomerTasks{Customer: customer, Tasks: []*Task{task}}); err != nil {
+		t.Fatalf("SaveTasks() error = %v", err)
+	}
+	// more than a batch across a year boundary, entries start in pairs to exercise paging on ties
+	base := time.Date(2023, 12, 31, 20, 0, 0, 0, time.UTC)
+	var entries []*Entry
+	for i := 0; i < walkBatchSize+10; i++ {
+		entries = append(entries, NewEntry(task, base.Add(time.Duration(i/2)*time.Hour)))
+	}
+	if err := b.SaveEntries(entries...); err != nil {
+		t.Fatalf("SaveEntries() error = %v", err)
+	}
+	want, err := b.LoadEntries(customer.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("LoadEntries() error = %v", err)
+	}
+	if len(want) != len(entries) {
+		t.Fatalf("LoadEntries() = %d entries, want %d", len(want), len(entries))
+	}
+
+	var got []*Entry
+	err = b.WalkEntries(context.Background(), customer.ID, time.Time{}, time.Time{}, func(e *Entry) error {
+		if len(got) > 0 && e.StartTS.Before(got[len(got)-1].StartTS) {
+			t.Errorf("WalkEntries() yielded %v after %v", e.StartTS, got[len(got)-1].StartTS)
+		}
+		got = append(got, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkEntries() error = %v", err)
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, e := range got {
+		seen[e.ID] = true
+	}
+	if len(got) != len(entries) || len(seen) != len(entries) {
+		t.Errorf("WalkEntries() yielded %d entries, %d distinct, want %d", len(got), len(seen), len(entries))
+	}
+
+	calls := 0
+	err = b.WalkEntries(context.Background(), customer.ID, base.Add(time.Hour), time.Time{}, func(e *Entry) error {
+		calls++
+		if calls == 3 {
+			return ErrStopWalk
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("WalkEntries() stopped = %v after %d calls, want nil after 3", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = b.WalkEntries(ctx, customer.ID, time.Time{}, time.Time{}, func(e *Entry) error {
+		t.Fatal("WalkEntries() called fn after cancellation")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("WalkEntries() cancelled error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func testBackendSaveEntries(t *testing.T, b Backend, atomic bool) {
+	customer := &Customer{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"), Name: "Test Customer"}
+	task := &Task{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174100"), Customer: customer, Name: "Task"}
+	if err := b.SaveCustomer(customer); err != nil {
+		t.Fatalf("SaveCustomer() error = %v", err)
+	}
+	if err := b.SaveTasks(&CustomerTasks{Customer: customer, Tasks: []*Task{task}}); err != nil {
+		t.Fatalf("SaveTasks() error = %v", err)
+	}
+	base := time.Date(2024, 12, 31, 22, 0, 0, 0, time.UTC)
+	first, second := NewEntry(task, base), NewEntry(task, base.Add(3*time.Hour))
+	if err := b.SaveEntries(first, second); err != nil {
+		t.Fatalf("SaveEntries() error = %v", err)
+	}
+	got, err := b.LoadEntries(customer.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("LoadEntries() error = %v", err)
+	}
+	assertEntries(t, got, []*Entry{first, second})
+
+	// moving an entry to another day must not leave the old version behind
+	second.StartTS = base.Add(time.Hour)
+	if err := b.SaveEntries(second); err != nil {
+		t.Fatalf("SaveEntries() error = %v", err)
+	}
+	got, err = b.LoadEntries(customer.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("LoadEntries() error = %v", err)
+	}
+	assertEntries(t, got, []*Entry{first, second})
+
+	if !atomic {
+		return
+	}
+	third := NewEntry(task, base.Add(5*time.Hour))
+	broken := &Entry{ID: uuid.New(), StartTS: base.Add(6 * time.Hour)}
+	if err := b.SaveEntries(third, broken); err == nil {
+		t.Fatalf("SaveEntries() with an entry without task should fail")
+	}
+	got, err = b.LoadEntries(customer.ID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("LoadEntries() error = %v", err)
+	}
+	assertEntries(t, got, []*Entry{first, second})
+}
+
+// testBackendStore checks that a Store works on top of the backend, it closes the backend.
+func testBackendStore(t *testing.T, b Backend) {
+	s, err := Open(t.TempDir(), Options{Backend: b})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	customer := NewCustomer("Test Customer")
+	if err := s.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := s.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: customer, Name: "Task"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := s.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	e := NewEntry(task, time.Now())
+	if err := s.SaveEntry(e); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	got, err := s.LoadCurrentEntry(customer)
+	if err != nil {
+		t.Fatalf("Store.LoadCurrentEntry() error = %v", err)
+	}
+	if got.ID != e.ID || got.Task == nil || got.Task.ID != task.ID || got.Task.Customer.ID != customer.ID {
+		t.Errorf("Store.LoadCurrentEntry() = %v, want %v", got, e)
+	}
+}
+
+// assertEntries compares entries by the fields backends must round trip.
+func assertEntries(t *testing.T, got, want []*Entry) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i := range got {
+		g, w := got[i], want[i]
+		if g.ID != w.ID || g.Comment != w.Comment || !g.StartTS.Equal(w.StartTS) {
+			t.Errorf("entry %d = %v, want %v", i, g, w)
+		}
+		if (g.EndTs == nil) != (w.EndTs == nil) || (g.EndTs != nil && !g.EndTs.Equal(*w.EndTs)) {
+			t.Errorf("entry %d end = %v, want %v", i, g.EndTs, w.EndTs)
+		}
+		if g.reference() != w.reference() {
+			t.Errorf("entry %d task = %v, want %v", i, g.reference(), w.reference())
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	src := NewJSONBackend(t.TempDir())
+	customer := &Customer{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"), Name: "Test Customer"}
+	task := &Task{ID: uuid.MustParse("123e4567-e89b-12d3-a456-426614174100"), Customer: customer, ExternalID: "PRJ-1", Name: "Task"}
+	if err := src.SaveCustomer(customer); err != nil {
+		t.Fatalf("SaveCustomer() error = %v", err)
+	}
+	if err := src.SaveTasks(&CustomerTasks{Customer: customer, Tasks: []*Task{task}}); err != nil {
+		t.Fatalf("SaveTasks() error = %v", err)
+	}
+	zone := time.FixedZone("ART", -3*60*60)
+	for i := 0; i < 3; i++ {
+		e := NewEntry(task, time.Date(2024, 3, 1+i, 23, 30, 0, 0, zone))
+		end := e.StartTS.Add(20 * time.Minute)
+		e.EndTs = &end
+		e.Comment = "# notes\n\n- converted"
+		if err := src.SaveEntry(e); err != nil {
+			t.Fatalf("SaveEntry() error = %v", err)
+		}
+	}
+
+	db, err := OpenSQLiteBackend(filepath.Join(t.TempDir(), sqliteFileName))
+	if err != nil {
+		t.Fatalf("OpenSQLiteBackend() error = %v", err)
+	}
+	defer db.Close()
+	if err := Convert(db, src); err != nil {
+		t.Fatalf("Convert() to sqlite error = %v", err)
+	}
+	dst := NewJSONBackend(t.TempDir())
+	if err := Convert(dst, db); err != nil {
+		t.Fatalf("Convert() from sqlite error = %v", err)
+	}
+
+	want := readTree(t, src.root)
+	got := readTree(t, dst.root)
+	if len(got) != len(want) {
+		t.Fatalf("converted tree has %d files, want %d", len(got), len(want))
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("converted %s = %s, want %s", name, got[name], content)
+		}
+	}
+}
+
+func TestOpen_ConfiguredBackend(t *testing.T) {
+	root := t.TempDir()
+	cfg := &Config{Backend: BackendSQLite}
+	if err := cfg.Save(root); err != nil {
+		t.Fatalf("Config.Save() error = %v", err)
+	}
+	s, err := Open(root, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	if _, ok := s.Backend().(*SQLiteBackend); !ok {
+		t.Errorf("Open() backend = %T, want *SQLiteBackend", s.Backend())
+	}
+}
+
+// readTree returns the content of every file under root keyed by its relative path.
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading tree %s: %v", root, err)
+	}
+	return files
+}
@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltFileName is the name of the database file in the data root when the bolt backend is used.
+const boltFileName = "ballandchain.bolt"
+
+// Top level buckets, tasks, entries and entry keys hold one nested bucket per customer.
+var (
+	boltCustomers  = []byte("customers")
+	boltTasks      = []byte("tasks")
+	boltEntries    = []byte("entries")
+	boltEntryKeys  = []byte("entry_keys")
+	boltTopBuckets = [][]byte{boltCustomers, boltTasks, boltEntries, boltEntryKeys}
+)
+
+// BoltBackend stores everything in a bbolt key-value file.
+//
+// Entries are keyed by their start time followed by their ID so a cursor walks them in
+// chronological order, entry keys map an entry ID to its current key.
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+// OpenBoltBackend opens, creating it if needed, the bbolt database at path.
+func OpenBoltBackend(path string) (*BoltBackend, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt database %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range boltTopBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("creating bucket %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltBackend{db: db}, nil
+}
+
+// boltTimeKey encodes t so that keys sort chronologically, including times before 1970.
+func boltTimeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano())^(1<<63))
+	return key
+}
+
+// boltEntryKey is the key of an entry in its customer entries bucket.
+func boltEntryKey(e *Entry) []byte {
+	return append(boltTimeKey(e.StartTS), e.ID[:]...)
+}
+
+// SaveCustomer implements Backend.
+func (b *BoltBackend) SaveCustomer(c *Customer) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encode customer: %w", err)
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCustomers).Put(c.ID[:], data)
+	})
+}
+
+// LoadCustomer implements Backend.
+func (b *BoltBackend) LoadCustomer(id uuid.UUID) (*Customer, error) {
+	c := &Customer{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltCustomers).Get(id[:])
+		if data == nil {
+			return fmt.Errorf("customer %s does not exist: %w", id, ErrNotFound)
+		}
+		return json.Unmarshal(data, c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadCustomers implements Backend.
+func (b *BoltBackend) LoadCustomers() ([]Customer, error) {
+	var customers []Customer
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCustomers).ForEach(func(_, data []byte) error {
+			var c Customer
+			if err := json.Unmarshal(data, &c); err != nil {
+				return fmt.Errorf("decode customer: %w", err)
+			}
+			customers = append(customers, c)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return customers, nil
+}
+
+// DeleteCustomer implements Backend.
+func (b *BoltBackend) DeleteCustomer(id uuid.UUID) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltTasks, boltEntries, boltEntryKeys} {
+			err := tx.Bucket(name).DeleteBucket(id[:])
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return fmt.Errorf("deleting %s of customer %s: %w", name, id, err)
+			}
+		}
+		return tx.Bucket(boltCustomers).Delete(id[:])
+	})
+}
+
+// SaveTasks implements Backend, tasks are keyed by position to keep their order.
+func (b *BoltBackend) SaveTasks(ct *CustomerTasks) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		tasks := tx.Bucket(boltTasks)
+		customerID := ct.Customer.ID
+		if err := tasks.DeleteBucket(customerID[:]); err != nil && err != bolt.ErrBucketNotFound {
+			return fmt.Errorf("clearing tasks: %w", err)
+		}
+		bucket, err := tasks.CreateBucket(customerID[:])
+		if err != nil {
+			return fmt.Errorf("creating tasks bucket: %w", err)
+		}
+		for i, t := range ct.Tasks {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return fmt.Errorf("encode task %s: %w", t.ID, err)
+			}
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, uint64(i))
+			if err := bucket.Put(key, data); err != nil {
+				return fmt.Errorf("save task %s: %w", t.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// LoadTasks implements Backend.
+func (b *BoltBackend) LoadTasks(c *Customer) (*CustomerTasks, error) {
+	ct := &CustomerTasks{
+		Customer: c,
+		Tasks:    []*Task{},
+	}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltTasks).Bucket(c.ID[:])
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, data []byte) error {
+			t := &Task{}
+			if err := json.Unmarshal(data, t); err != nil {
+				return fmt.Errorf("decode task: %w", err)
+			}
+			t.Customer = c
+			ct.Tasks = append(ct.Tasks, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+// SaveEntry implements Backend.
+func (b *BoltBackend) SaveEntry(e *Entry) error {
+	return b.SaveEntries(e)
+}
+
+// SaveEntries implements Backend, all entries are saved in one transaction.
+func (b *BoltBackend) SaveEntries(entries ...*Entry) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, e := range entries {
+			if err := saveBoltEntry(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveBoltEntry puts an entry within the given transaction, dropping its previous key if it moved.
+func saveBoltEntry(tx *bolt.Tx, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode entry %s: %w", e.ID, err)
+	}
+	customerID := e.reference().CustomerID
+	entries, err := tx.Bucket(boltEntries).CreateBucketIfNotExists(customerID[:])
+	if err != nil {
+		return fmt.Errorf("creating entries bucket: %w", err)
+	}
+	keys, err := tx.Bucket(boltEntryKeys).CreateBucketIfNotExists(customerID[:])
+	if err != nil {
+		return fmt.Errorf("creating entry keys bucket: %w", err)
+	}
+	key := boltEntryKey(e)
+	if previous := keys.Get(e.ID[:]); previous != nil && !bytes.Equal(previous, key) {
+		if err := entries.Delete(previous); err != nil {
+			return fmt.Errorf("removing previous version of entry %s: %w", e.ID, err)
+		}
+	}
+	if err := entries.Put(key, data); err != nil {
+		return fmt.Errorf("save entry %s: %w", e.ID, err)
+	}
+	if err := keys.Put(e.ID[:], key); err != nil {
+		return fmt.Errorf("save entry key %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+// DeleteEntry implements Backend.
+func (b *BoltBackend) DeleteEntry(e *Entry) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		customerID := e.reference().CustomerID
+		keys := tx.Bucket(boltEntryKeys).Bucket(customerID[:])
+		var key []byte
+		if keys != nil {
+			key = keys.Get(e.ID[:])
+		}
+		if key == nil {
+			return fmt.Errorf("entry %s: %w", e.ID, ErrNotFound)
+		}
+		if err := tx.Bucket(boltEntries).Bucket(customerID[:]).Delete(key); err != nil {
+			return fmt.Errorf("delete entry %s: %w", e.ID, err)
+		}
+		return keys.Delete(e.ID[:])
+	})
+}
+
+// LoadEntries implements Backend, it seeks straight to the first entry in range.
+func (b *BoltBackend) LoadEntries(customerID uuid.UUID, from, to time.Time) ([]*Entry, error) {
+	var entries []*Entry
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltEntries).Bucket(customerID[:])
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		var k, data []byte
+		if from.IsZero() {
+			k, data = c.First()
+		} else {
+			k, data = c.Seek(boltTimeKey(from))
+		}
+		var upper []byte
+		if !to.IsZero() {
+			upper = boltTimeKey(to)
+		}
+		for ; k != nil; k, data = c.Next() {
+			if upper != nil && bytes.Compare(k[:8], upper) >= 0 {
+				break
+			}
+			e := &Entry{}
+			if err := json.Unmarshal(data, e); err != nil {
+				return fmt.Errorf("decode entry: %w", err)
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WalkEntries implements Backend, it reads the entries in batches so no transaction stays
+// open while fn runs.
+func (b *BoltBackend) WalkEntries(ctx context.Context, customerID uuid.UUID, from, to time.Time, fn func(e *Entry) error) error {
+	var after []byte
+	for {
+		batch, last, err := b.entryBatch(customerID, from, to, after)
+		if err != nil {
+			return err
+		}
+		for _, e := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(e); err != nil {
+				return walkResult(err)
+			}
+		}
+		if len(batch) < walkBatchSize {
+			return nil
+		}
+		after = last
+	}
+}
+
+// entryBatch returns up to walkBatchSize entries started in [from, to) stored after the key
+// after, or from the start of the range if it is nil, along with the key of the last one.
+func (b *BoltBackend) entryBatch(customerID uuid.UUID, from, to time.Time, after []byte) ([]*Entry, []byte, error) {
+	var entries []*Entry
+	var last []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltEntries).Bucket(customerID[:])
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		var k, data []byte
+		switch {
+		case after != nil:
+			k, data = c.Seek(after)
+			if bytes.Equal(k, after) {
+				k, data = c.Next()
+			}
+		case from.IsZero():
+			k, data = c.First()
+		default:
+			k, data = c.Seek(boltTimeKey(from))
+		}
+		var upper []byte
+		if !to.IsZero() {
+			upper = boltTimeKey(to)
+		}
+		for ; k != nil && len(entries) < walkBatchSize; k, data = c.Next() {
+			if upper != nil && bytes.Compare(k[:8], upper) >= 0 {
+				break
+			}
+			e := &Entry{}
+			if err := json.Unmarshal(data, e); err != nil {
+				return fmt.Errorf("decode entry: %w", err)
+			}
+			entries = append(entries, e)
+			// keys are only valid within the transaction
+			last = append(last[:0], k...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, last, nil
+}
+
+// LastEntry implements Backend.
+func (b *BoltBackend) LastEntry(customerID uuid.UUID) (*Entry, error) {
+	e := &Entry{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltEntries).Bucket(customerID[:])
+		var data []byte
+		if bucket != nil {
+			_, data = bucket.Cursor().Last()
+		}
+		if data == nil {
+			return fmt.Errorf("no entries for customer %s: %w", customerID, ErrNotFound)
+		}
+		return json.Unmarshal(data, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Close implements Backend.
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
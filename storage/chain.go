@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ChainProblem is a broken link found by Store.VerifyChain.
+type ChainProblem struct {
+	Seq     uint64    // ledger record involved, 0 if none is
+	EntryID uuid.UUID // entry involved, zero if none is
+	Reason  string
+}
+
+// String describes the problem.
+func (p ChainProblem) String() string {
+	switch {
+	case p.Seq != 0 && p.EntryID != uuid.Nil:
+		return fmt.Sprintf("record %d, entry %s: %s", p.Seq, p.EntryID, p.Reason)
+	case p.Seq != 0:
+		return fmt.Sprintf("record %d: %s", p.Seq, p.Reason)
+	default:
+		return fmt.Sprintf("entry %s: %s", p.EntryID, p.Reason)
+	}
+}
+
+// Seal is a billing period closed with Store.Seal.
+type Seal struct {
+	CustomerID uuid.UUID
+	Seq        uint64
+	From       time.Time
+	To         time.Time
+	Hash       string // hash of the seal record, it covers the whole chain up to it
+}
+
+// chain links the entries about to be saved or deleted to the ledgers of their customers,
+// setting Seq and PrevHash of saved ones, and returns the function recording them once the
+// backend succeeded. Entries started, now or before, within a sealed period fail with ErrSealed.
+// The root lock must be held exclusively.
+func (s *Store) chain(op string, entries ...*Entry) (func() error, error) {
+	ledgers := map[uuid.UUID]*ledger{}
+	pending := map[uuid.UUID][]ledgerRecord{}
+	for _, e := range entries {
+		customerID := e.reference().CustomerID
+		l, ok := ledgers[customerID]
+		if !ok {
+			var err error
+			if l, err = loadLedger(s.root, customerID); err != nil {
+				return nil, err
+			}
+			ledgers[customerID] = l
+		}
+		if seal := l.sealing(e.StartTS); seal != nil {
+			return nil, fmt.Errorf("entry %s starts within the period sealed by record %d: %w", e.ID, seal.Seq, ErrSealed)
+		}
+		if previous, ok := l.state()[e.ID]; ok && previous.Op == ledgerSave {
+			if seal := l.sealing(previous.Start); seal != nil {
+				return nil, fmt.Errorf("entry %s started within the period sealed by record %d: %w", e.ID, seal.Seq, ErrSealed)
+			}
+		}
+		r := ledgerRecord{Op: op, EntryID: e.ID, Start: e.StartTS}
+		if op == ledgerSave {
+			seq, prev := l.head()
+			if records := pending[customerID]; len(records) > 0 {
+				seq, prev = records[len(records)-1].Seq, records[len(records)-1].Hash
+			}
+			e.Seq, e.PrevHash = seq+1, prev
+			hash, err := hashEntry(e)
+			if err != nil {
+				return nil, err
+			}
+			r.EntryHash = hash
+		}
+		l.link(&r, pending[customerID])
+		pending[customerID] = append(pending[customerID], r)
+	}
+	return func() error {
+		for customerID, records := range pending {
+			if err := ledgers[customerID].append(records...); err != nil {
+				return fmt.Errorf("recording changes in the ledger of customer %s: %w", customerID, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// Seal closes the entries of a customer started within [from, to), they cannot be changed
+// afterwards. Handing the seal hash to the customer lets them detect the chain being rewritten.
+func (s *Store) Seal(customerID uuid.UUID, from, to time.Time) (*Seal, error) {
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return nil, fmt.Errorf("invalid period to seal from %s to %s", from, to)
+	}
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	l, err := loadLedger(s.root, customerID)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := s.entryHashes(customerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	r := ledgerRecord{Op: ledgerSeal, EntryHash: hashSealed(hashes), Start: from, End: to}
+	l.link(&r, nil)
+	if err := l.append(r); err != nil {
+		return nil, err
+	}
+	if err := s.commit("seal entries of %s from %s to %s", customerID, from.Format(time.DateOnly), to.Format(time.DateOnly)); err != nil {
+		return nil, err
+	}
+	return &Seal{CustomerID: customerID, Seq: r.Seq, From: from, To: to, Hash: r.Hash}, nil
+}
+
+// entryHashes returns the hashes of the entries of a customer started within [from, to).
+func (s *Store) entryHashes(customerID uuid.UUID, from, to time.Time) (map[uuid.UUID]string, error) {
+	entries, err := s.backend.LoadEntries(customerID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("loading entries of customer %s: %w", customerID, err)
+	}
+	hashes := make(map[uuid.UUID]string, len(entries))
+	for _, e := range entries {
+		if hashes[e.ID], err = hashEntry(e); err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+// VerifyChain walks the ledger of a customer and checks it against the saved entries, it
+// reports every broken link: records that were rewritten, entries changed, added or removed
+// without going through the store and sealed periods whose entries differ from when sealed.
+func (s *Store) VerifyChain(customerID uuid.UUID) ([]ChainProblem, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	l, err := loadLedger(s.root, customerID)
+	if err != nil {
+		return nil, err
+	}
+	var problems []ChainProblem
+	var seq uint64
+	prev := ""
+	for _, r := range l.records {
+		switch {
+		case r.Seq != seq+1:
+			problems = append(problems, ChainProblem{Seq: r.Seq, EntryID: r.EntryID, Reason: fmt.Sprintf("follows record %d", seq)})
+		case r.Prev != prev:
+			problems = append(problems, ChainProblem{Seq: r.Seq, EntryID: r.EntryID, Reason: "does not link to the previous record"})
+		case r.computeHash() != r.Hash:
+			problems = append(problems, ChainProblem{Seq: r.Seq, EntryID: r.EntryID, Reason: "was rewritten"})
+		}
+		seq, prev = r.Seq, r.Hash
+	}
+
+	state := l.state()
+	seen := map[uuid.UUID]bool{}
+	hashes := map[uuid.UUID]string{}
+	starts := map[uuid.UUID]time.Time{}
+	err = s.backend.WalkEntries(context.Background(), customerID, time.Time{}, time.Time{}, func(e *Entry) error {
+		seen[e.ID] = true
+		hash, err := hashEntry(e)
+		if err != nil {
+			return err
+		}
+		hashes[e.ID], starts[e.ID] = hash, e.StartTS
+		r, ok := state[e.ID]
+		switch {
+		case !ok:
+			problems = append(problems, ChainProblem{EntryID: e.ID, Reason: "is not in the chain"})
+		case r.Op == ledgerDelete:
+			problems = append(problems, ChainProblem{Seq: r.Seq, EntryID: e.ID, Reason: "was deleted but is back"})
+		case r.EntryHash != hash:
+			problems = append(problems, ChainProblem{Seq: r.Seq, EntryID: e.ID, Reason: "changed outside the chain"})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking entries of customer %s: %w", customerID, err)
+	}
+	for id, r := range state {
+		if r.Op == ledgerSave && !seen[id] {
+			problems = append(problems, ChainProblem{Seq: r.Seq, EntryID: id, Reason: "is missing"})
+		}
+	}
+
+	for _, r := range l.records {
+		if r.Op != ledgerSeal {
+			continue
+		}
+		sealed := map[uuid.UUID]string{}
+		for id, hash := range hashes {
+			if inRange(starts[id], r.Start, r.End) {
+				sealed[id] = hash
+			}
+		}
+		if hashSealed(sealed) != r.EntryHash {
+			problems = append(problems, ChainProblem{Seq: r.Seq, Reason: "sealed entries changed"})
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newChainStore opens a store holding three finished entries of a customer on consecutive days.
+func newChainStore(t *testing.T) (*Store, *Task, []*Entry) {
+	t.Helper()
+	s, err := Open(t.TempDir(), Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	customer := NewCustomer("Test Customer")
+	if err := s.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := s.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: customer, Name: "Task"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := s.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	var entries []*Entry
+	for day := 2; day <= 4; day++ {
+		e := NewEntry(task, time.Date(2024, 1, day, 9, 0, 0, 0, time.UTC))
+		end := e.StartTS.Add(time.Hour)
+		e.EndTs = &end
+		if err := s.SaveEntry(e); err != nil {
+			t.Fatalf("Store.SaveEntry() error = %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return s, task, entries
+}
+
+// problemReasons returns the sorted reasons of the problems found in the chain of a customer.
+func problemReasons(t *testing.T, s *Store, customerID uuid.UUID) []string {
+	t.Helper()
+	problems, err := s.VerifyChain(customerID)
+	if err != nil {
+		t.Fatalf("Store.VerifyChain() error = %v", err)
+	}
+	var reasons []string
+	for _, p := range problems {
+		reasons = append(reasons, p.Reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// rewriteLedger applies edit to the lines of the ledger of a customer.
+func rewriteLedger(t *testing.T, s *Store, customerID uuid.UUID, edit func(lines []string) []string) {
+	t.Helper()
+	path := ledgerPath(s.root, customerID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := edit(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_VerifyChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, s *Store, entries []*Entry)
+		want   []string
+	}{
+		{
+			name:   "untouched",
+			tamper: func(t *testing.T, s *Store, entries []*Entry) {},
+		},
+		{
+			name: "entry edited",
+			tamper: func(t *testing.T, s *Store, entries []*Entry) {
+				entries[1].Comment = "billable, promise"
+				if err := entries[1].save(s.root); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"changed outside the chain"},
+		},
+		{
+			name: "entry removed",
+			tamper: func(t *testing.T, s *Store, entries []*Entry) {
+				if err := os.Remove(s.backend.(*JSONBackend).entryPath(entries[1])); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"is missing"},
+		},
+		{
+			name: "entry added",
+			tamper: func(t *testing.T, s *Store, entries []*Entry) {
+				e := NewEntry(entries[0].Task, entries[0].StartTS.Add(2*time.Hour))
+				if err := e.save(s.root); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"is not in the chain"},
+		},
+		{
+			name: "record rewritten",
+			tamper: func(t *testing.T, s *Store, entries []*Entry) {
+				rewriteLedger(t, s, entries[0].Task.Customer.ID, func(lines []string) []string {
+					lines[1] = strings.Replace(lines[1], `"op":"save"`, `"op":"delete"`, 1)
+					return lines
+				})
+			},
+			want: []string{"was deleted but is back", "was rewritten"},
+		},
+		{
+			name: "record removed",
+			tamper: func(t *testing.T, s *Store, entries []*Entry) {
+				rewriteLedger(t, s, entries[0].Task.Customer.ID, func(lines []string) []string {
+					return append(lines[:1], lines[2:]...)
+				})
+			},
+			want: []string{"follows record 1", "is not in the chain"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, entries := newChainStore(t)
+			for i, e := range entries {
+				if e.Seq != uint64(i+1) || (i > 0) == (e.PrevHash == "") {
+					t.Fatalf("entry %d Seq = %d, PrevHash = %q, want it linked to the previous one", i, e.Seq, e.PrevHash)
+				}
+			}
+			tt.tamper(t, s, entries)
+			got := problemReasons(t, s, entries[0].Task.Customer.ID)
+			if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("Store.VerifyChain() reasons = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_Seal(t *testing.T) {
+	s, task, entries := newChainStore(t)
+	customerID := task.Customer.ID
+	seal, err := s.Seal(customerID, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Store.Seal() error = %v", err)
+	}
+	if seal.Seq != 4 || seal.Hash == "" {
+		t.Errorf("Store.Seal() = %+v, want the fourth record", seal)
+	}
+
+	entries[0].Comment = "rewritten"
+	if err := s.SaveEntry(entries[0]); !errors.Is(err, ErrSealed) {
+		t.Errorf("Store.SaveEntry() of a sealed entry error = %v, want ErrSealed", err)
+	}
+	if err := s.DeleteEntry(entries[1]); !errors.Is(err, ErrSealed) {
+		t.Errorf("Store.DeleteEntry() of a sealed entry error = %v, want ErrSealed", err)
+	}
+	if err := s.SaveEntry(NewEntry(task, time.Date(2024, 1, 3, 18, 0, 0, 0, time.UTC))); !errors.Is(err, ErrSealed) {
+		t.Errorf("Store.SaveEntry() of a new entry in a sealed period error = %v, want ErrSealed", err)
+	}
+	moved := *entries[2]
+	moved.StartTS = time.Date(2024, 1, 3, 18, 0, 0, 0, time.UTC)
+	if err := s.SaveEntry(&moved); !errors.Is(err, ErrSealed) {
+		t.Errorf("Store.SaveEntry() of an entry moved in a sealed period error = %v, want ErrSealed", err)
+	}
+	entries[2].Comment = "after the period"
+	if err := s.SaveEntry(entries[2]); err != nil {
+		t.Errorf("Store.SaveEntry() after the sealed period error = %v", err)
+	}
+	if got := problemReasons(t, s, customerID); len(got) != 0 {
+		t.Fatalf("Store.VerifyChain() reasons = %q, want none", got)
+	}
+
+	// the file still holds the original comment, edit it behind the store back
+	if err := entries[0].save(s.root); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"changed outside the chain", "sealed entries changed"}
+	if got := problemReasons(t, s, customerID); strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("Store.VerifyChain() reasons = %q, want %q", got, want)
+	}
+}
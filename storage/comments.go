@@ -0,0 +1,414 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commentIndexSchema versions how comments are indexed, bumping it rebuilds the stored comment index.
+const commentIndexSchema = "1"
+
+// defaultCommentSearchLimit is how many entries SearchComments returns unless told otherwise.
+const defaultCommentSearchLimit = 20
+
+// commentFacetSize is how many terms a comment facet counts at most.
+const commentFacetSize = 100
+
+// schemaKey is the internal bleve key holding the schema an index was built with.
+var schemaKey = []byte("schema")
+
+// commentIndexPath returns the folder of the comment index, shared by all customers.
+func commentIndexPath(root string) string {
+	return filepath.Join(indexPath(root), "comments.bleve")
+}
+
+// headKey is the internal bleve key holding the ledger head of a customer when its entries were
+// last indexed, a different head means entries were saved without updating the index.
+func headKey(customerID uuid.UUID) []byte {
+	return []byte("head/" + customerID.String())
+}
+
+// commentDocument is what gets indexed of an entry with a comment, under the entry ID.
+type commentDocument struct {
+	Comment    string    `json:"comment"`
+	Start      time.Time `json:"start"`
+	Year       string    `json:"year"`
+	Month      string    `json:"month"`
+	CustomerID string    `json:"customer_id"`
+	TaskID     string    `json:"task_id"`
+}
+
+// newCommentDocument returns the document indexing an entry, years and months are those of the
+// store timezone.
+func (s *Store) newCommentDocument(e *Entry) commentDocument {
+	ref := e.reference()
+	start := e.StartTS.In(s.location)
+	return commentDocument{
+		Comment:    e.Comment,
+		Start:      e.StartTS,
+		Year:       start.Format("2006"),
+		Month:      start.Format("2006-01"),
+		CustomerID: ref.CustomerID.String(),
+		TaskID:     ref.TaskID.String(),
+	}
+}
+
+// commentIndexMapping indexes comments as english text, stemmed so "migrating" finds "migration",
+// and keeps the rest as facetable keywords.
+func commentIndexMapping() mapping.IndexMapping {
+	m := bleve.NewIndexMapping()
+	comment := bleve.NewTextFieldMapping()
+	comment.Analyzer = en.AnalyzerName
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("comment", comment)
+	doc.AddFieldMappingsAt("start", bleve.NewDateTimeFieldMapping())
+	for _, field := range []string{"year", "month", "customer_id", "task_id"} {
+		f := bleve.NewTextFieldMapping()
+		f.Analyzer = keyword.Name
+		f.IncludeInAll = false
+		doc.AddFieldMappingsAt(field, f)
+	}
+	m.DefaultMapping = doc
+	return m
+}
+
+// comments returns the comment index, opening it on first use. The entries of customers saved
+// since it was last updated, by another process or a store without it open, get indexed again.
+// s.mu must be held.
+func (s *Store) comments() (bleve.Index, error) {
+	if s.commentIndex != nil {
+		return s.commentIndex, nil
+	}
+	var index bleve.Index
+	fresh := true
+	if s.indexLock != nil {
+		path := commentIndexPath(s.root)
+		if stored, err := bleve.Open(path); err == nil {
+			if schema, err := stored.GetInternal(schemaKey); err == nil && string(schema) == commentIndexSchema {
+				index, fresh = stored, false
+			} else {
+				stored.Close()
+			}
+		}
+		if index == nil {
+			if err := os.RemoveAll(path); err != nil {
+				return nil, fmt.Errorf("removing comment index: %w", err)
+			}
+			created, err := bleve.New(path, commentIndexMapping())
+			if err != nil {
+				return nil, fmt.Errorf("creating comment index: %w", err)
+			}
+			index = created
+		}
+	} else {
+		created, err := bleve.NewMemOnly(commentIndexMapping())
+		if err != nil {
+			return nil, fmt.Errorf("creating comment index: %w", err)
+		}
+		index = created
+	}
+	for id := range s.customers {
+		l, err := loadLedger(s.root, id)
+		if err != nil {
+			index.Close()
+			return nil, err
+		}
+		_, head := l.head()
+		if !fresh {
+			if stored, err := index.GetInternal(headKey(id)); err == nil && string(stored) == head {
+				continue
+			}
+		}
+		if err := s.indexCustomerComments(index, id, head); err != nil {
+			index.Close()
+			return nil, err
+		}
+	}
+	if err := index.SetInternal(schemaKey, []byte(commentIndexSchema)); err != nil {
+		index.Close()
+		return nil, fmt.Errorf("writing comment index schema: %w", err)
+	}
+	s.commentIndex = index
+	return index, nil
+}
+
+// indexCustomerComments replaces the indexed comments of a customer by those of its saved entries.
+func (s *Store) indexCustomerComments(index bleve.Index, customerID uuid.UUID, head string) error {
+	if err := deleteCustomerComments(index, customerID); err != nil {
+		return err
+	}
+	batch := index.NewBatch()
+	err := s.backend.WalkEntries(context.Background(), customerID, time.Time{}, time.Time{}, func(e *Entry) error {
+		if e.Comment == "" {
+			return nil
+		}
+		if err := batch.Index(e.ID.String(), s.newCommentDocument(e)); err != nil {
+			return err
+		}
+		if batch.Size() < walkBatchSize {
+			return nil
+		}
+		err := index.Batch(batch)
+		batch.Reset()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("indexing comments of customer %s: %w", customerID, err)
+	}
+	batch.SetInternal(headKey(customerID), []byte(head))
+	if err := index.Batch(batch); err != nil {
+		return fmt.Errorf("indexing comments of customer %s: %w", customerID, err)
+	}
+	return nil
+}
+
+// deleteCustomerComments removes the indexed comments of a customer.
+func deleteCustomerComments(index bleve.Index, customerID uuid.UUID) error {
+	q := bleve.NewTermQuery(customerID.String())
+	q.SetField("customer_id")
+	for {
+		result, err := index.Search(bleve.NewSearchRequestOptions(q, walkBatchSize, 0, false))
+		if err != nil {
+			return fmt.Errorf("finding comments of customer %s: %w", customerID, err)
+		}
+		if len(result.Hits) == 0 {
+			return nil
+		}
+		batch := index.NewBatch()
+		for _, hit := range result.Hits {
+			batch.Delete(hit.ID)
+		}
+		if err := index.Batch(batch); err != nil {
+			return fmt.Errorf("removing comments of customer %s: %w", customerID, err)
+		}
+	}
+}
+
+// updateComments indexes the comments of entries just saved or deleted through the store, the
+// root lock must be held exclusively. Nothing is done before the index is first used.
+func (s *Store) updateComments(deleted bool, entries ...*Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.commentIndex == nil {
+		return nil
+	}
+	batch := s.commentIndex.NewBatch()
+	customers := map[uuid.UUID]bool{}
+	for _, e := range entries {
+		customers[e.reference().CustomerID] = true
+		if deleted || e.Comment == "" {
+			batch.Delete(e.ID.String())
+			continue
+		}
+		if err := batch.Index(e.ID.String(), s.newCommentDocument(e)); err != nil {
+			return fmt.Errorf("indexing comment of entry %s: %w", e.ID, err)
+		}
+	}
+	for id := range customers {
+		l, err := loadLedger(s.root, id)
+		if err != nil {
+			return err
+		}
+		_, head := l.head()
+		batch.SetInternal(headKey(id), []byte(head))
+	}
+	if err := s.commentIndex.Batch(batch); err != nil {
+		return fmt.Errorf("indexing comments: %w", err)
+	}
+	return nil
+}
+
+// closeComments closes the comment index, it gets opened and brought up to date again on next
+// use. With forget the stored index is removed so that happens from scratch, s.mu must be held.
+func (s *Store) closeComments(forget bool) error {
+	if s.commentIndex != nil {
+		if err := s.commentIndex.Close(); err != nil {
+			return fmt.Errorf("closing comment index: %w", err)
+		}
+		s.commentIndex = nil
+	}
+	if forget && s.indexLock != nil {
+		if err := os.RemoveAll(commentIndexPath(s.root)); err != nil {
+			return fmt.Errorf("removing comment index: %w", err)
+		}
+	}
+	return nil
+}
+
+// CommentSearchOptions narrows a comment search, the zero value searches every entry.
+type CommentSearchOptions struct {
+	CustomerID uuid.UUID // only entries of this customer
+	TaskID     uuid.UUID // only entries of this task
+	From       time.Time // only entries started at or after From
+	To         time.Time // only entries started before To
+	Limit      int       // most entries returned, defaults to 20
+}
+
+// CommentMatch is an entry found by SearchComments.
+type CommentMatch struct {
+	Entry      *Entry
+	Score      float64
+	Highlights []Highlight // the words of the comment that matched
+}
+
+// FacetCount is how many entries matching a search share a value, like a month or a customer.
+type FacetCount struct {
+	Value string // "2024", "2024-05" or the ID of a customer or task
+	Name  string // name of the customer or task
+	Count int
+}
+
+// CommentResults are the entries matching a comment search, best first, along with how all
+// the matching ones spread over years, months, customers and tasks.
+type CommentResults struct {
+	Total     uint64
+	Matches   []CommentMatch
+	Years     []FacetCount
+	Months    []FacetCount
+	Customers []FacetCount
+	Tasks     []FacetCount
+}
+
+// SearchComments finds the entries whose comment holds every word of q, stemmed, those holding
+// them as a phrase first.
+func (s *Store) SearchComments(q string, opts CommentSearchOptions) (*CommentResults, error) {
+	words := strings.Fields(strings.ToLower(q))
+	if len(words) == 0 {
+		return &CommentResults{}, nil
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultCommentSearchLimit
+	}
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	s.mu.Lock()
+	index, err := s.comments()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	all := bleve.NewMatchQuery(q)
+	all.SetField("comment")
+	all.SetOperator(query.MatchQueryOperatorAnd)
+	phrase := bleve.NewMatchPhraseQuery(q)
+	phrase.SetField("comment")
+	phrase.SetBoost(2)
+	text := bleve.NewBooleanQuery()
+	text.AddMust(all)
+	text.AddShould(phrase)
+	filtered := bleve.NewConjunctionQuery(text)
+	for field, id := range map[string]uuid.UUID{"customer_id": opts.CustomerID, "task_id": opts.TaskID} {
+		if id != uuid.Nil {
+			term := bleve.NewTermQuery(id.String())
+			term.SetField(field)
+			filtered.AddQuery(term)
+		}
+	}
+	if !opts.From.IsZero() || !opts.To.IsZero() {
+		period := bleve.NewDateRangeQuery(opts.From, opts.To)
+		period.SetField("start")
+		filtered.AddQuery(period)
+	}
+	req := bleve.NewSearchRequestOptions(filtered, limit, 0, false)
+	req.Fields = []string{"customer_id", "start"}
+	req.IncludeLocations = true
+	for name, field := range map[string]string{"years": "year", "months": "month", "customers": "customer_id", "tasks": "task_id"} {
+		req.AddFacet(name, bleve.NewFacetRequest(field, commentFacetSize))
+	}
+	result, err := index.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("searching comments for %q: %w", q, err)
+	}
+
+	results := &CommentResults{Total: result.Total}
+	for _, hit := range result.Hits {
+		e, err := s.findEntry(hit.ID, hit.Fields)
+		if err != nil {
+			// saved behind the back of the index, it catches up on the next reindex
+			continue
+		}
+		results.Matches = append(results.Matches, CommentMatch{Entry: e, Score: hit.Score, Highlights: highlights(hit.Locations, words, "comment")})
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	results.Years = facetCounts(result.Facets["years"], nil)
+	results.Months = facetCounts(result.Facets["months"], nil)
+	results.Customers = facetCounts(result.Facets["customers"], func(value string) string {
+		id, _ := uuid.Parse(value)
+		return s.customers[id].Name
+	})
+	results.Tasks = facetCounts(result.Facets["tasks"], func(value string) string {
+		id, _ := uuid.Parse(value)
+		for _, tasks := range s.tasks {
+			if t, ok := tasks[id]; ok {
+				return t.Name
+			}
+		}
+		return ""
+	})
+	return results, nil
+}
+
+// findEntry loads the entry of a comment hit from the customer and start stored in the index.
+func (s *Store) findEntry(entryID string, fields map[string]interface{}) (*Entry, error) {
+	id, err := uuid.Parse(entryID)
+	if err != nil {
+		return nil, err
+	}
+	customerID, err := uuid.Parse(fmt.Sprint(fields["customer_id"]))
+	if err != nil {
+		return nil, err
+	}
+	start, err := time.Parse(time.RFC3339, fmt.Sprint(fields["start"]))
+	if err != nil {
+		return nil, err
+	}
+	return s.loadEntry(customerID, id, start)
+}
+
+// loadEntry loads and resolves an entry of a customer started within the second of start.
+func (s *Store) loadEntry(customerID, id uuid.UUID, start time.Time) (*Entry, error) {
+	start = start.Truncate(time.Second)
+	entries, err := s.backend.LoadEntries(customerID, start, start.Add(time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("loading entry %s: %w", id, err)
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return e, s.resolveEntry(e)
+		}
+	}
+	return nil, fmt.Errorf("entry %s: %w", id, ErrNotFound)
+}
+
+// facetCounts converts a term facet, naming its values with name when given.
+func facetCounts(facet *search.FacetResult, name func(value string) string) []FacetCount {
+	if facet == nil {
+		return nil
+	}
+	counts := make([]FacetCount, 0, len(facet.Terms))
+	for _, term := range facet.Terms {
+		c := FacetCount{Value: term.Term, Count: term.Count}
+		if name != nil {
+			c.Name = name(term.Term)
+		}
+		counts = append(counts, c)
+	}
+	return counts
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStore_SearchComments(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root, Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { s.Close() }()
+	acme, globex := NewCustomer("Acme"), NewCustomer("Globex")
+	tasks := map[*Customer]*Task{}
+	for _, c := range []*Customer{acme, globex} {
+		if err := s.AddCustomer(c); err != nil {
+			t.Fatalf("Store.AddCustomer() error = %v", err)
+		}
+		ct, err := s.LoadTasks(c.ID)
+		if err != nil {
+			t.Fatalf("Store.LoadTasks() error = %v", err)
+		}
+		tasks[c] = &Task{ID: uuid.New(), Customer: c, Name: "Backend"}
+		if err := ct.AddTask(tasks[c]); err != nil {
+			t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+		}
+		if err := s.SaveTasks(ct); err != nil {
+			t.Fatalf("Store.SaveTasks() error = %v", err)
+		}
+	}
+	save := func(c *Customer, start time.Time, comment string) *Entry {
+		t.Helper()
+		e := NewEntry(tasks[c], start)
+		e.Comment = comment
+		if err := s.SaveEntry(e); err != nil {
+			t.Fatalf("Store.SaveEntry() error = %v", err)
+		}
+		return e
+	}
+	oauth := save(acme, time.Date(2023, 11, 6, 9, 0, 0, 0, time.UTC), "Started the **OAuth migration**")
+	save(acme, time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC), "Reviewed OAuth scopes")
+	database := save(globex, time.Date(2024, 2, 2, 9, 0, 0, 0, time.UTC), "- migrating the database")
+	save(globex, time.Date(2024, 2, 3, 9, 0, 0, 0, time.UTC), "")
+
+	search := func(q string, opts CommentSearchOptions) *CommentResults {
+		t.Helper()
+		results, err := s.SearchComments(q, opts)
+		if err != nil {
+			t.Fatalf("Store.SearchComments() error = %v", err)
+		}
+		return results
+	}
+	ids := func(results *CommentResults) []uuid.UUID {
+		var ids []uuid.UUID
+		for _, m := range results.Matches {
+			ids = append(ids, m.Entry.ID)
+		}
+		return ids
+	}
+
+	results := search("OAuth migration", CommentSearchOptions{})
+	if got := ids(results); !reflect.DeepEqual(got, []uuid.UUID{oauth.ID}) {
+		t.Fatalf("Store.SearchComments() = %v, want the OAuth migration", got)
+	}
+	if got, want := results.Matches[0].Highlights, []Highlight{{"comment", 14, 19}, {"comment", 20, 29}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Store.SearchComments() highlights = %v, want %v", got, want)
+	}
+	if results.Matches[0].Entry.Task == nil || results.Matches[0].Entry.Task.Name != "Backend" {
+		t.Errorf("Store.SearchComments() entry task = %v, want it resolved", results.Matches[0].Entry.Task)
+	}
+
+	results = search("migration", CommentSearchOptions{})
+	if results.Total != 2 {
+		t.Errorf("Store.SearchComments() total = %d, want migration and migrating", results.Total)
+	}
+	wantYears := []FacetCount{{Value: "2023", Count: 1}, {Value: "2024", Count: 1}}
+	if !reflect.DeepEqual(results.Years, wantYears) {
+		t.Errorf("Store.SearchComments() years = %v, want %v", results.Years, wantYears)
+	}
+	if len(results.Customers) != 2 || results.Customers[0].Name == "" {
+		t.Errorf("Store.SearchComments() customers = %v, want both named", results.Customers)
+	}
+	if got := ids(search("migration", CommentSearchOptions{CustomerID: globex.ID})); !reflect.DeepEqual(got, []uuid.UUID{database.ID}) {
+		t.Errorf("Store.SearchComments() of Globex = %v, want the database", got)
+	}
+	if got := ids(search("migration", CommentSearchOptions{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})); !reflect.DeepEqual(got, []uuid.UUID{database.ID}) {
+		t.Errorf("Store.SearchComments() of 2024 = %v, want the database", got)
+	}
+
+	// saves update the open index
+	oauth.Comment = "Finished the OAuth migration"
+	if err := s.SaveEntry(oauth); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	if got := search("finished", CommentSearchOptions{}); got.Total != 1 {
+		t.Errorf("Store.SearchComments() after saving = %d, want the new comment", got.Total)
+	}
+	if err := s.DeleteEntry(database); err != nil {
+		t.Fatalf("Store.DeleteEntry() error = %v", err)
+	}
+	if got := search("database", CommentSearchOptions{}); got.Total != 0 {
+		t.Errorf("Store.SearchComments() after deleting = %d, want none", got.Total)
+	}
+
+	// entries saved while the index is closed are picked up when it opens again
+	s.Close()
+	if s, err = Open(root, Options{Location: time.UTC}); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	save(globex, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), "Kicked off the SSO migration")
+	if got := search("migration", CommentSearchOptions{}); got.Total != 2 {
+		t.Errorf("Store.SearchComments() after reopening = %d, want OAuth and SSO", got.Total)
+	}
+}
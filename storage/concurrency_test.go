@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestStore_Concurrent hammers a store with loads, additions and searches at the same time,
+// run it with -race to catch unsynchronized access.
+func TestStore_Concurrent(t *testing.T) {
+	for _, tb := range testBackends {
+		t.Run(tb.name, func(t *testing.T) {
+			s, err := Open(t.TempDir(), Options{Backend: tb.newBackend(t)})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer s.Close()
+			shared := NewCustomer("Shared Customer")
+			if err := s.AddCustomer(shared); err != nil {
+				t.Fatalf("Store.AddCustomer() error = %v", err)
+			}
+			ct, err := s.LoadTasks(shared.ID)
+			if err != nil {
+				t.Fatalf("Store.LoadTasks() error = %v", err)
+			}
+			sharedTask := &Task{ID: uuid.New(), Customer: shared, Name: "shared task"}
+			if err := ct.AddTask(sharedTask); err != nil {
+				t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+			}
+			if err := s.SaveTasks(ct); err != nil {
+				t.Fatalf("Store.SaveTasks() error = %v", err)
+			}
+
+			const workers, rounds = 4, 10
+			errs := make(chan error, 3*workers*rounds)
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(3)
+				// adders, each with a customer of their own since CustomerTasks is not shared
+				go func() {
+					defer wg.Done()
+					c := NewCustomer("Customer")
+					if err := s.AddCustomer(c); err != nil {
+						errs <- err
+						return
+					}
+					ct, err := s.LoadTasks(c.ID)
+					if err != nil {
+						errs <- err
+						return
+					}
+					for i := 0; i < rounds; i++ {
+						task := &Task{ID: uuid.New(), Customer: c, Name: "task"}
+						if err := ct.AddTask(task); err != nil {
+							errs <- err
+						}
+						if err := s.SaveTasks(ct); err != nil {
+							errs <- err
+						}
+						if err := s.SaveEntry(NewEntry(task, time.Now())); err != nil {
+							errs <- err
+						}
+					}
+				}()
+				// loaders
+				go func() {
+					defer wg.Done()
+					for i := 0; i < rounds; i++ {
+						if err := s.SaveEntry(NewEntry(sharedTask, time.Now())); err != nil {
+							errs <- err
+						}
+						if _, err := s.LoadDayEntries(shared, time.Now()); err != nil {
+							errs <- err
+						}
+						if _, err := s.LoadTasks(shared.ID); err != nil {
+							errs <- err
+						}
+					}
+				}()
+				// searchers
+				go func() {
+					defer wg.Done()
+					for i := 0; i < rounds; i++ {
+						if _, err := s.SearchTasks("shared", TaskSearchOptions{}); err != nil {
+							errs <- err
+						}
+						if _, err := s.Task(shared.ID, sharedTask.ID); err != nil {
+							errs <- err
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	_ "time/tzdata" // configured timezones resolve on systems without a zoneinfo database
+)
+
+// Backend names accepted in Config.Backend.
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+	BackendBolt   = "bolt"
+)
+
+// Config holds the settings stored in root/config.json, a missing file means defaults.
+type Config struct {
+	// Backend selects how data is persisted, it defaults to BackendJSON.
+	// Use Convert to move existing data before changing it.
+	Backend string `json:"backend,omitempty"`
+	// Timezone is the IANA name of the timezone whose midnights split entries into days,
+	// it defaults to the local one.
+	Timezone string `json:"timezone,omitempty"`
+	// VersionControl commits the data root to git after every change made through a Store.
+	VersionControl bool `json:"version_control,omitempty"`
+	// Online syncs the data root with its git remote, it implies VersionControl.
+	Online bool `json:"online,omitempty"`
+	// Remote is the URL of the git remote the data root syncs with.
+	Remote string `json:"remote,omitempty"`
+	// ParallelTimers lets several timers run at once, by default starting one finishes the other.
+	ParallelTimers bool `json:"parallel_timers,omitempty"`
+}
+
+// configPath returns the path of the config file for root.
+func configPath(root string) string {
+	return filepath.Join(root, "config.json")
+}
+
+// LoadConfig reads the config of the given data root.
+func LoadConfig(root string) (*Config, error) {
+	unlock, err := lockRoot(root, false, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	cfg := &Config{}
+	f, err := os.Open(configPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(cfg); err != nil {
+		return nil, fmt.Errorf("decode config file: %w", err)
+	}
+	return cfg, nil
+}
+
+// Save persists the config in the given data root.
+func (c *Config) Save(root string) error {
+	unlock, err := lockRoot(root, true, DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		return fmt.Errorf("could not create directory %s: %w", root, err)
+	}
+	return writeFileAtomic(configPath(root), func(w io.Writer) error {
+		m := json.NewEncoder(w)
+		m.SetIndent("", "  ")
+		if err := m.Encode(c); err != nil {
+			return fmt.Errorf("could not save config: %w", err)
+		}
+		return nil
+	})
+}
+
+// Location returns the timezone selected by the config.
+func (c *Config) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("loading timezone %q: %w", c.Timezone, err)
+	}
+	return loc, nil
+}
+
+// OpenBackend opens the backend selected by the config for the given data root.
+func (c *Config) OpenBackend(root string) (Backend, error) {
+	switch c.Backend {
+	case "", BackendJSON:
+		return NewJSONBackend(root), nil
+	case BackendSQLite:
+		return OpenSQLiteBackend(filepath.Join(root, sqliteFileName))
+	case BackendBolt:
+		return OpenBoltBackend(filepath.Join(root, boltFileName))
+	default:
+		return nil, fmt.Errorf("unknown backend %q", c.Backend)
+	}
+}
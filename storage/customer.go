@@ -5,14 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"io"
 	"os"
 	"path/filepath"
 )
 
 // Customer represents a customer of the time tracking human.
 type Customer struct {
-	ID   uuid.UUID `json:"id"`
-	Name string    `json:"name"`
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Archived bool      `json:"archived,omitempty"` // hidden from listings and searches, its data is kept
 }
 
 // NewCustomer instantiates a customer object
@@ -30,33 +32,40 @@ func (c *Customer) SavePath(root string) string {
 
 // EnsureFolder tries to create the customers folder or fails.
 func (c *Customer) EnsureFolder(root string) (string, error) {
+	unlock, err := lockRoot(root, true, DefaultLockTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
 	customerSavePath := c.SavePath(root)
-	err := os.MkdirAll(customerSavePath, os.ModePerm)
+	err = os.MkdirAll(customerSavePath, os.ModePerm)
 	if err != nil {
 		return "", fmt.Errorf("could not create directory %s: %v", customerSavePath, err)
 	}
 	return customerSavePath, nil
 }
 
-// Save saves a customer metadata
-func (c *Customer) Save(root string) error {
+// save saves a customer metadata
+func (c *Customer) save(root string) error {
+	unlock, err := lockRoot(root, true, DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	customerSavePath, err := c.EnsureFolder(root)
 	if err != nil {
 		return fmt.Errorf("ensuring customer folder: %v", err)
 	}
 	customerMetadataPath := filepath.Join(customerSavePath, "metadata.json")
-	f, err := os.Create(customerMetadataPath)
-	if err != nil {
-		return fmt.Errorf("could not create customer metadata file: %w", err)
-	}
-	defer f.Close()
-	m := json.NewEncoder(f)
-	m.SetIndent("", "  ")
-	err = m.Encode(c)
-	if err != nil {
-		return fmt.Errorf("could not save customer metadata: %w", err)
-	}
-	return nil
+	return writeFileAtomic(customerMetadataPath, func(w io.Writer) error {
+		m := json.NewEncoder(w)
+		m.SetIndent("", "  ")
+		err := m.Encode(c)
+		if err != nil {
+			return fmt.Errorf("could not save customer metadata: %w", err)
+		}
+		return nil
+	})
 }
 
 // ErrNotFound should be returned when the requested customer cannot be found.
@@ -64,10 +73,15 @@ var ErrNotFound = errors.New("customer not found")
 
 // LoadCustomer reads a customer from disk.
 func LoadCustomer(root string, id uuid.UUID) (*Customer, error) {
+	unlock, err := lockRoot(root, false, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	c := &Customer{ID: id}
 	customerPath := c.SavePath(root)
 	if _, err := os.Stat(customerPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("customer %s does not exist: %w", id, err)
+		return nil, fmt.Errorf("customer %s does not exist: %w", id, ErrNotFound)
 	}
 	customerMetadataPath := filepath.Join(customerPath, "metadata.json")
 	f, err := os.Open(customerMetadataPath)
@@ -85,12 +99,16 @@ func LoadCustomer(root string, id uuid.UUID) (*Customer, error) {
 
 // LoadAllCustomers loads all customers for this system
 func LoadAllCustomers(root string) ([]Customer, error) {
+	unlock, err := lockRoot(root, false, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	customersFolder := filepath.Join(root, "customers")
 	matches, err := filepath.Glob(filepath.Join(customersFolder, "*"))
 	if err != nil {
 		return nil, fmt.Errorf("glob customers: %w", err)
 	}
-	fmt.Printf("Found %d customers in %s\n", len(matches), filepath.Join(customersFolder, "*"))
 	customers := make([]Customer, len(matches))
 	for i, match := range matches {
 		match = filepath.Base(match)
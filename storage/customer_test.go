@@ -95,22 +95,22 @@ func TestCustomer_Save(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.customer.Save(tt.root); (err != nil) != tt.wantErr {
-				t.Errorf("Customer.Save() error = %v, wantErr %v", err, tt.wantErr)
+			if err := tt.customer.save(tt.root); (err != nil) != tt.wantErr {
+				t.Errorf("Customer.save() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			// Also check that the file exists and has the expected content
 			customerPath := filepath.Join(tt.customer.SavePath(tt.root), "metadata.json")
 			f, err := os.Open(customerPath)
 			if err != nil {
-				t.Fatalf("Customer.Save() did not create the file: %v", err)
+				t.Fatalf("Customer.save() did not create the file: %v", err)
 			}
 			defer f.Close()
 			var gotJSON json.RawMessage
 			if err := json.NewDecoder(f).Decode(&gotJSON); err != nil {
-				t.Fatalf("Customer.Save() could not decode the file: %v", err)
+				t.Fatalf("Customer.save() could not decode the file: %v", err)
 			}
 			if string(gotJSON) != string(tt.expectedJSON) {
-				t.Errorf("Customer.Save() = %s, want %s", string(gotJSON), string(tt.expectedJSON))
+				t.Errorf("Customer.save() = %s, want %s", string(gotJSON), string(tt.expectedJSON))
 			}
 		})
 	}
@@ -122,7 +122,7 @@ func TestLoadCustomer(t *testing.T) {
 		ID:   uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
 		Name: "Test Customer",
 	}
-	if err := customer.Save(tmpFolder); err != nil {
+	if err := customer.save(tmpFolder); err != nil {
 		t.Fatalf("Failed to save customer: %v", err)
 	}
 
@@ -170,10 +170,10 @@ func TestLoadAllCustomers(t *testing.T) {
 		ID:   uuid.MustParse("123e4567-e89b-12d3-a456-426614174001"),
 		Name: "Test Customer 2",
 	}
-	if err := customer1.Save(tmpFolder); err != nil {
+	if err := customer1.save(tmpFolder); err != nil {
 		t.Fatalf("Failed to save customer1: %v", err)
 	}
-	if err := customer2.Save(tmpFolder); err != nil {
+	if err := customer2.save(tmpFolder); err != nil {
 		t.Fatalf("Failed to save customer2: %v", err)
 	}
 
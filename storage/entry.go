@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +21,29 @@ type Entry struct {
 	Comment string     `json:"comment"`
 	StartTS time.Time  `json:"start_ts"`
 	EndTs   *time.Time `json:"end_ts,omitempty"`
+	Tags    []string   `json:"tags,omitempty"`
+	Breaks  []Break    `json:"breaks,omitempty"` // pauses taken while running, see Store.PauseEntry
+	// SessionID links the fragments of an entry split at midnight, it is the ID of the first one.
+	SessionID uuid.UUID `json:"session_id"`
+	// Seq and PrevHash place the entry in the ledger of its customer, see Store.VerifyChain.
+	Seq      uint64 `json:"seq,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
+
+	ref taskRef // task reference as decoded, see Store.resolveEntry
+}
+
+// reference returns the task reference of this entry, from its task when it has one.
+func (e *Entry) reference() taskRef {
+	if e.Task != nil && e.Task.Customer != nil {
+		return taskRef{CustomerID: e.Task.Customer.ID, TaskID: e.Task.ID}
+	}
+	return e.ref
+}
+
+// taskRef identifies a task by its customer and its own ID.
+type taskRef struct {
+	CustomerID uuid.UUID
+	TaskID     uuid.UUID
 }
 
 // Entries is a slice of Entry, it implements the required methods for sorting
@@ -46,128 +69,70 @@ func dateComp[T ~int](d T) string {
 }
 
 func dayPath(savePath string, date time.Time) string {
-	return filepath.Join(filepath.Dir(savePath), dateComp(date.Year()), dateComp(date.Month()), dateComp(date.Day()))
+	return filepath.Join(savePath, dateComp(date.Year()), dateComp(date.Month()), dateComp(date.Day()))
 }
 
-// latestDateFolder returns the latest date folder in the given root
-func latestDateFolder(root string) (string, error) {
-	years, err := os.ReadDir(root)
-	if err != nil {
-		return "", fmt.Errorf("reading root directory: %w", err)
-	}
-
-	var yearDirs []int
-	for _, year := range years {
-		if year.IsDir() {
-			yearInt, err := strconv.Atoi(year.Name())
-			if err == nil {
-				yearDirs = append(yearDirs, yearInt)
-			}
-		}
-	}
-
-	if len(yearDirs) == 0 {
-		return "", fmt.Errorf("no valid year directories found")
-	}
-
-	sort.Sort(sort.Reverse(sort.IntSlice(yearDirs)))
-	latestYear := yearDirs[0]
-	yearPath := filepath.Join(root, strconv.Itoa(latestYear))
-
-	months, err := os.ReadDir(yearPath)
+// save will add an entry to the root/tasks/{customerID}/{year}/{month}/{day}/{entryID}.json
+func (e *Entry) save(root string) error {
+	unlock, err := lockRoot(root, true, DefaultLockTimeout)
 	if err != nil {
-		return "", fmt.Errorf("reading year directory: %w", err)
-	}
-
-	var monthDirs = make([]int, 0, 12)
-	for _, month := range months {
-		if month.IsDir() {
-			monthInt, err := strconv.Atoi(month.Name())
-			if err == nil {
-				monthDirs = append(monthDirs, monthInt)
-			}
-		}
-	}
-
-	if len(monthDirs) == 0 {
-		return "", fmt.Errorf("no valid month directories found")
-	}
-
-	sort.Sort(sort.Reverse(sort.IntSlice(monthDirs)))
-	latestMonth := monthDirs[0]
-	monthPath := filepath.Join(yearPath, strconv.Itoa(latestMonth))
-
-	days, err := os.ReadDir(monthPath)
-	if err != nil {
-		return "", fmt.Errorf("reading month directory: %w", err)
-	}
-
-	var dayDirs = make([]int, 0, 31)
-	for _, day := range days {
-		if day.IsDir() {
-			dayInt, err := strconv.Atoi(day.Name())
-			if err == nil {
-				dayDirs = append(dayDirs, dayInt)
-			}
-		}
-	}
-
-	if len(dayDirs) == 0 {
-		return "", fmt.Errorf("no valid day directories found")
+		return err
 	}
-
-	sort.Sort(sort.Reverse(sort.IntSlice(dayDirs)))
-	latestDay := dayDirs[0]
-	latestDayPath := filepath.Join(monthPath, strconv.Itoa(latestDay))
-
-	return latestDayPath, nil
-}
-
-// Save will add an entry to the root/tasks/{customerID}/{year}/{month}/{day}/{entryID}.json
-func (e *Entry) Save(root string) error {
+	defer unlock()
 	entriesSavePath, err := e.Task.EnsureTaskEntriesFolder(root)
 	if err != nil {
 		return fmt.Errorf("could not create entries folder: %w", err)
 	}
-	entryPath := filepath.Join(dayPath(entriesSavePath, e.StartTS), e.ID.String()+".json")
-	f, err := os.Create(entryPath)
-	if err != nil {
-		return fmt.Errorf("could not create entry file: %w", err)
-	}
-	defer f.Close()
-	if err := json.NewEncoder(f).Encode(e); err != nil {
-		return fmt.Errorf("could not encode entry: %w", err)
+	entryDayPath := dayPath(entriesSavePath, e.StartTS)
+	if err := os.MkdirAll(entryDayPath, os.ModePerm); err != nil {
+		return fmt.Errorf("could not create entry day folder %s: %w", entryDayPath, err)
 	}
-	return nil
+	entryPath := filepath.Join(entryDayPath, e.ID.String()+".json")
+	return writeFileAtomic(entryPath, func(w io.Writer) error {
+		if err := json.NewEncoder(w).Encode(e); err != nil {
+			return fmt.Errorf("could not encode entry: %w", err)
+		}
+		return nil
+	})
 }
 
+// plainEntry has the fields of Entry but none of its methods, so it can be embedded by the
+// json methods without recursing into them.
+type plainEntry Entry
+
 // MarshalJSON method for Entry to be able to marshal task
 func (e *Entry) MarshalJSON() ([]byte, error) {
-	if e.Task == nil {
+	ref := e.reference()
+	if ref.TaskID == uuid.Nil {
 		return nil, errors.New("task is nil")
 	}
-	if e.Task.Customer == nil {
+	if ref.CustomerID == uuid.Nil {
 		return nil, errors.New("customer is nil")
 	}
 	// Create a shadow type to avoid infinite recursion
 	alias := &struct {
-		TaskID string `json:"task"`
-		*Entry
+		TaskID    string     `json:"task"`
+		SessionID *uuid.UUID `json:"session_id,omitempty"`
+		*plainEntry
 	}{
-		TaskID: path.Join(e.Task.Customer.ID.String(), e.Task.ID.String()),
-		Entry:  e,
+		TaskID:     path.Join(ref.CustomerID.String(), ref.TaskID.String()),
+		plainEntry: (*plainEntry)(e),
+	}
+	if e.SessionID != uuid.Nil {
+		alias.SessionID = &e.SessionID
 	}
 
 	return json.MarshalIndent(alias, "", "  ")
 }
 
-// UnmarshalJSON method for Entry to be able to unmarshal task
+// UnmarshalJSON method for Entry to be able to unmarshal task, the task reference is only
+// recorded, it gets resolved by the Store that loads the entry.
 func (e *Entry) UnmarshalJSON(data []byte) error {
 	aux := &struct {
 		TaskID string `json:"task"`
-		*Entry
+		*plainEntry
 	}{
-		Entry: e,
+		plainEntry: (*plainEntry)(e),
 	}
 
 	if err := json.Unmarshal(data, aux); err != nil {
@@ -184,115 +149,157 @@ func (e *Entry) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return fmt.Errorf("could not parse customer ID: %w", err)
 	}
-	c, ok := taskFromID[customerUUID]
-	if !ok {
-		return fmt.Errorf("could not find customer ID in task list %q", customerUUID)
-	}
-	task, ok := c[taskUUID]
-	if !ok {
-		return fmt.Errorf("could not find task %q", taskUUID)
-	}
-	e.Task = &task
+	e.ref = taskRef{CustomerID: customerUUID, TaskID: taskUUID}
+	e.Task = nil
 	return nil
 }
 
-// Finish sets the end date for the given entry and persists result
-func (e *Entry) Finish(root string) error {
-	now := time.Now()
-	if e.StartTS.YearDay() != now.YearDay() { //I am aware this breaks if you left it running for a year
-		endTS := time.Date(e.StartTS.Year(), e.StartTS.Month(), e.StartTS.Day(), 23, 59, 59, 0, time.UTC)
-		e.EndTs = &endTS
-		// Now lets try to bridge the gap of  "various days of work"
-		delta := e.StartTS.Sub(now)
-		deltaInDays := int(delta.Hours() / 24)
-		for i := 0; i < deltaInDays; i++ {
-			var startT, endT time.Time
-			startD := e.StartTS.Add(time.Duration(i+1) * 24 * time.Hour)
-			startT = time.Date(startD.Year(), startD.Month(), startD.Day(), 0, 0, 0, 0, time.UTC)
-			if i+1 == deltaInDays {
-				endT = now
-			} else {
-				endT = time.Date(startD.Year(), startD.Month(), startD.Day(), 23, 59, 59, 0, time.UTC)
-			}
-			ee := &Entry{
-				ID:      e.ID,
-				Task:    e.Task,
-				Comment: e.Comment,
-				StartTS: startT,
-				EndTs:   &endT,
-			}
-			if err := ee.Save(root); err != nil {
-				return fmt.Errorf("could not save intermediate entry after finishing: %w", err)
-			}
-		}
+// FinishEntry ends the given entry now and persists it, see FinishEntryAt.
+func (s *Store) FinishEntry(e *Entry) error {
+	return s.FinishEntryAt(e, time.Now())
+}
+
+// FinishEntryAt ends the given entry at end and persists it, an entry running past midnight in
+// the store timezone is saved as one fragment per day sharing the same SessionID.
+func (s *Store) FinishEntryAt(e *Entry, end time.Time) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	fragments, err := splitEntry(e, end, s.location)
+	if err != nil {
+		return err
 	}
-	e.EndTs = &now
-	if err := e.Save(root); err != nil {
+	record, err := s.chain(ledgerSave, fragments...)
+	if err != nil {
+		return err
+	}
+	// all fragments go in at once so backends supporting it never keep half a finished entry
+	if err := s.backend.SaveEntries(fragments...); err != nil {
 		return fmt.Errorf("could not save entry after finishing: %w", err)
 	}
-	return nil
-}
-
-type EntryStorage struct {
-	Root           string
-	VersionControl bool // git or not
-	Online         bool // to push
+	if err := record(); err != nil {
+		return err
+	}
+	if err := s.updateActive(false, fragments...); err != nil {
+		return err
+	}
+	if err := s.updateComments(false, fragments...); err != nil {
+		return err
+	}
+	return s.commit("finish entry %s", s.describeEntry(e))
 }
 
 // LoadLatestDayEntries loads all entries for the given customer on the latest date available
-func LoadLatestDayEntries(root string, customer *Customer) ([]*Entry, error) {
-	latestDayPath, err := latestDateFolder(EntriesSavePath(root, customer))
+func (s *Store) LoadLatestDayEntries(customer *Customer) ([]*Entry, error) {
+	last, err := s.backend.LastEntry(customer.ID)
 	if err != nil {
-		return nil, fmt.Errorf("could not find latest date folder: %w", err)
+		return nil, fmt.Errorf("could not find latest entry: %w", err)
 	}
-	return LoadPathEntries(latestDayPath)
+	return s.LoadDayEntries(customer, last.StartTS)
 }
 
 // LoadDayEntries loads all entries for the given customer on the given date
-func LoadDayEntries(root string, customer *Customer, date time.Time) ([]*Entry, error) {
-	esp := EntriesSavePath(root, customer)
-	dayEntriesPath := dayPath(esp, date)
-	return LoadPathEntries(dayEntriesPath)
+func (s *Store) LoadDayEntries(customer *Customer, date time.Time) ([]*Entry, error) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return s.LoadEntriesBetween(customer, from, from.AddDate(0, 0, 1))
 }
 
-func LoadPathEntries(entriesPath string) ([]*Entry, error) {
-	// now load all entries for that day which are in the form of json files
-	entries, err := os.ReadDir(entriesPath)
+// LoadEntriesBetween loads all entries for the given customer started within [from, to) sorted by start date
+func (s *Store) LoadEntriesBetween(customer *Customer, from, to time.Time) ([]*Entry, error) {
+	unlock, err := s.lock(false)
 	if err != nil {
-		return nil, fmt.Errorf("reading day directory: %w", err)
+		return nil, err
 	}
-	var dayEntries = make([]*Entry, 0, len(entries))
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		entryPath := filepath.Join(entriesPath, entry.Name())
-		f, err := os.Open(entryPath)
-		if err != nil {
-			return nil, fmt.Errorf("open entry file: %w", err)
-		}
-		var e Entry
-		err = json.NewDecoder(f).Decode(&e)
-		f.Close()
-		if err != nil {
-			return nil, fmt.Errorf("decode entry file: %w", err)
+	defer unlock()
+	entries, err := s.backend.LoadEntries(customer.ID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("loading entries for customer %s: %w", customer.Name, err)
+	}
+	for _, e := range entries {
+		if err := s.resolveEntry(e); err != nil {
+			return nil, fmt.Errorf("resolving entry %s: %w", e.ID, err)
 		}
-		dayEntries = append(dayEntries, &e)
 	}
-	sort.Sort(Entries(dayEntries))
-	return dayEntries, nil
+	return entries, nil
+}
+
+// SaveEntry persists the given entry and links it to the chain of its customer, an entry not
+// finished yet is recorded as running, see Start.
+func (s *Store) SaveEntry(e *Entry) error {
+	action := "save"
+	if e.EndTs == nil {
+		action = "start"
+	}
+	return s.saveEntry(e, action)
+}
+
+// saveEntry persists an entry like SaveEntry, action describes the change in the commit message.
+func (s *Store) saveEntry(e *Entry, action string) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	record, err := s.chain(ledgerSave, e)
+	if err != nil {
+		return err
+	}
+	if err := s.backend.SaveEntry(e); err != nil {
+		return fmt.Errorf("saving entry %s: %w", e.ID, err)
+	}
+	if err := record(); err != nil {
+		return err
+	}
+	if err := s.updateActive(false, e); err != nil {
+		return err
+	}
+	if err := s.updateComments(false, e); err != nil {
+		return err
+	}
+	return s.commit("%s entry %s", action, s.describeEntry(e))
 }
 
-// LoadCurrentEntry loads the latest open entry for the given customer.
-func LoadCurrentEntry(root string, customer *Customer) (*Entry, error) {
-	dayEntries, err := LoadDayEntries(root, customer, time.Now())
+// DeleteEntry removes the given entry.
+func (s *Store) DeleteEntry(e *Entry) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	record, err := s.chain(ledgerDelete, e)
 	if err != nil {
-		return nil, fmt.Errorf("loading day entries: %w", err)
+		return err
 	}
-	if len(dayEntries) == 0 {
-		return nil, ErrNotFound
+	if err := s.backend.DeleteEntry(e); err != nil {
+		return fmt.Errorf("deleting entry %s: %w", e.ID, err)
 	}
-	return dayEntries[len(dayEntries)-1], nil
+	if err := record(); err != nil {
+		return err
+	}
+	if err := s.updateActive(true, e); err != nil {
+		return err
+	}
+	if err := s.updateComments(true, e); err != nil {
+		return err
+	}
+	return s.commit("delete entry %s", s.describeEntry(e))
+}
+
+// LoadCurrentEntry loads the running entry of the given customer, whatever day it started, or
+// returns ErrNotFound. With parallel timers the last one started is returned.
+func (s *Store) LoadCurrentEntry(customer *Customer) (*Entry, error) {
+	running, err := s.Status()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(running) - 1; i >= 0; i-- {
+		if running[i].reference().CustomerID == customer.ID {
+			return running[i], nil
+		}
+	}
+	return nil, fmt.Errorf("running entry of customer %s: %w", customer.Name, ErrNotFound)
 }
 
 // NewEntry instantiates an entry for the given task and customer.
@@ -303,9 +310,3 @@ func NewEntry(task *Task, now time.Time) *Entry {
 		StartTS: now,
 	}
 }
-
-/*
-Take in account loading entries for a task within a time frame, which is what report does
-Also load entries for all tasks fo a customer within a time frame
-Finally load all entries, for v2 for a time frame, useful for self reporting
-*/
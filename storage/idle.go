@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// IdleAction is what happens to the time a running entry spent idle, see Store.ResolveIdle.
+type IdleAction int
+
+const (
+	// KeepIdle counts the idle time as worked, the entry is left as it is.
+	KeepIdle IdleAction = iota
+	// DiscardIdle drops the idle time, the entry ends when idleness started and another one
+	// on the same task starts on return.
+	DiscardIdle
+	// ReassignIdle books the idle time to another task, like DiscardIdle with an entry on that
+	// task in between.
+	ReassignIdle
+)
+
+// String names the action.
+func (a IdleAction) String() string {
+	switch a {
+	case KeepIdle:
+		return "keep"
+	case DiscardIdle:
+		return "discard"
+	case ReassignIdle:
+		return "reassign"
+	default:
+		return fmt.Sprintf("IdleAction(%d)", int(a))
+	}
+}
+
+// ResolveIdle applies action to the time the running entry e spent idle between from and to,
+// task is where ReassignIdle books it. The entry is split to match and the timer running on
+// return is returned, e itself when the idle time is kept. An entry idle since it started is
+// moved to start on return instead of being left empty. Every entry involved is saved at once,
+// so on failure e is left running as it was.
+func (s *Store) ResolveIdle(e *Entry, from, to time.Time, action IdleAction, task *Task) (*Entry, error) {
+	if e.EndTs != nil {
+		return nil, fmt.Errorf("resolving idle time of entry %s: %w", e.ID, ErrNotRunning)
+	}
+	if from.Before(e.StartTS) {
+		from = e.StartTS
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("entry %s cannot be idle from %s to %s", e.ID, from, to)
+	}
+	if action == ReassignIdle && task == nil {
+		return nil, fmt.Errorf("reassigning idle time of entry %s needs a task", e.ID)
+	}
+	switch action {
+	case KeepIdle:
+		return e, nil
+	case DiscardIdle, ReassignIdle:
+	default:
+		return nil, fmt.Errorf("unknown idle action %s", action)
+	}
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	// e is only changed once everything is saved
+	resolved := *e
+	var entries []*Entry
+	var next *Entry
+	if from.Equal(e.StartTS) {
+		next = &resolved
+		next.StartTS = to
+		next.Breaks = nil
+	} else {
+		fragments, err := splitEntry(&resolved, from, s.location)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fragments...)
+		// the entry on return carries on where e left off
+		next = NewEntry(e.Task, to)
+		next.Comment = e.Comment
+		next.Tags = append([]string(nil), e.Tags...)
+	}
+	if action == ReassignIdle {
+		fragments, err := splitEntry(NewEntry(task, from), to, s.location)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fragments...)
+	}
+	entries = append(entries, next)
+	record, err := s.chain(ledgerSave, entries...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.SaveEntries(entries...); err != nil {
+		return nil, fmt.Errorf("could not save entries resolving idle time: %w", err)
+	}
+	if err := record(); err != nil {
+		return nil, err
+	}
+	if err := s.updateActive(false, entries...); err != nil {
+		return nil, err
+	}
+	if err := s.updateComments(false, entries...); err != nil {
+		return nil, err
+	}
+	// like with FinishEntryAt e becomes its first fragment, when moved it is returned as next instead
+	if next != &resolved {
+		*e = resolved
+	}
+	if err := s.commit("%s idle time of entry %s", action, s.describeEntry(e)); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStore_ResolveIdle(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	back := start.Add(2 * time.Hour)
+	tests := []struct {
+		name        string
+		from        time.Time
+		action      IdleAction
+		finished    bool
+		wantFail    bool
+		wantErr     error
+		wantEntries int
+	}{
+		{name: "discard", from: start.Add(time.Hour), action: DiscardIdle, wantEntries: 2},
+		{name: "idle since it started", from: start.Add(-time.Hour), action: DiscardIdle, wantEntries: 1},
+		{name: "reassign without task", from: start.Add(time.Hour), action: ReassignIdle, wantFail: true, wantEntries: 1},
+		{name: "finished", from: start.Add(time.Hour), action: DiscardIdle, finished: true, wantFail: true, wantErr: ErrNotRunning, wantEntries: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tasks := newTimerStore(t, Options{})
+			e := NewEntry(tasks[0], start)
+			if _, err := s.Start(e); err != nil {
+				t.Fatalf("Store.Start() error = %v", err)
+			}
+			if tt.finished {
+				if err := s.FinishEntryAt(e, back); err != nil {
+					t.Fatalf("Store.FinishEntryAt() error = %v", err)
+				}
+			}
+			next, err := s.ResolveIdle(e, tt.from, back, tt.action, nil)
+			if (err != nil) != tt.wantFail || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Store.ResolveIdle() error = %v, want failure %v with %v", err, tt.wantFail, tt.wantErr)
+			}
+			if err == nil && (!next.StartTS.Equal(back) || next.EndTs != nil) {
+				t.Errorf("Store.ResolveIdle() = entry from %s, want one running from %s", next.StartTS, back)
+			}
+			entries, err := s.LoadEntries(EntryFilter{CustomerID: tasks[0].Customer.ID})
+			if err != nil {
+				t.Fatalf("Store.LoadEntries() error = %v", err)
+			}
+			if len(entries) != tt.wantEntries {
+				t.Errorf("Store.LoadEntries() = %d entries, want %d", len(entries), tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestStore_ResolveIdleFailure(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	s, tasks := newTimerStore(t, Options{})
+	e := NewEntry(tasks[0], start)
+	if _, err := s.Start(e); err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	// the idle time cannot be booked to a sealed day of the other customer
+	if _, err := s.Seal(tasks[1].Customer.ID, start.Truncate(24*time.Hour), start.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("Store.Seal() error = %v", err)
+	}
+	if _, err := s.ResolveIdle(e, start.Add(time.Hour), start.Add(2*time.Hour), ReassignIdle, tasks[1]); !errors.Is(err, ErrSealed) {
+		t.Fatalf("Store.ResolveIdle() error = %v, want ErrSealed", err)
+	}
+	if e.EndTs != nil {
+		t.Errorf("Store.ResolveIdle() finished the entry although it failed")
+	}
+	running, err := s.Status()
+	if err != nil {
+		t.Fatalf("Store.Status() error = %v", err)
+	}
+	if len(running) != 1 || running[0].ID != e.ID || !running[0].StartTS.Equal(start) {
+		t.Errorf("Store.Status() = %v, want entry %s still running from %s", running, e.ID, start)
+	}
+	for i, want := range []int{1, 0} {
+		entries, err := s.LoadEntries(EntryFilter{CustomerID: tasks[i].Customer.ID})
+		if err != nil {
+			t.Fatalf("Store.LoadEntries() error = %v", err)
+		}
+		if len(entries) != want {
+			t.Errorf("Store.LoadEntries() of %s = %d entries, want %d", tasks[i].Customer.Name, len(entries), want)
+		}
+	}
+	if problems, err := s.VerifyChain(tasks[0].Customer.ID); err != nil || len(problems) != 0 {
+		t.Errorf("Store.VerifyChain() = %v, %v, want no problems", problems, err)
+	}
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// taskIndexSchema versions how tasks are indexed, bumping it rebuilds every stored task index.
+const taskIndexSchema = 3
+
+// fingerprintKey is the internal bleve key holding what an index was built from.
+var fingerprintKey = []byte("fingerprint")
+
+// indexPath returns the folder holding the search indexes of a data root, they can always be
+// rebuilt from the data so they are kept out of version control:
+//
+//	root/index/.lock                     held by the process using the indexes
+//	root/index/tasks/{customerID}.bleve  task index of a customer
+//	root/index/comments.bleve            comment index of all entries
+func indexPath(root string) string {
+	return filepath.Join(root, "index")
+}
+
+// taskIndexPath returns the task index folder of a customer.
+func taskIndexPath(root string, customerID uuid.UUID) string {
+	return filepath.Join(indexPath(root), "tasks", customerID.String()+".bleve")
+}
+
+// lockIndexes takes the index folder for this store, indexes can only be opened by a process at a
+// time so when another one holds them the store keeps its indexes in memory instead.
+func (s *Store) lockIndexes() error {
+	dir := indexPath(s.root)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("could not create directory %s: %w", dir, err)
+	}
+	if err := writeIfMissing(filepath.Join(dir, ".gitignore"), "*\n"); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("open index lock file: %w", err)
+	}
+	ok, err := tryLockFile(f, true)
+	if err != nil || !ok {
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("locking indexes: %w", err)
+		}
+		return nil
+	}
+	s.indexLock = f
+	return nil
+}
+
+// IndexesInMemory tells if the search indexes are kept in memory because another process uses the
+// ones stored under the root, they are then rebuilt every time the store is opened.
+func (s *Store) IndexesInMemory() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.indexLock == nil
+}
+
+// unlockIndexes releases the index folder taken by lockIndexes.
+func (s *Store) unlockIndexes() {
+	if s.indexLock != nil {
+		unlockFile(s.indexLock)
+		s.indexLock.Close()
+		s.indexLock = nil
+	}
+}
+
+// taskDocument is what gets indexed of a task, under the task ID.
+type taskDocument struct {
+	Name       string `json:"name"`
+	ExternalID string `json:"external_id"`
+	CustomerID string `json:"customer_id"`
+	Archived   bool   `json:"archived"`
+}
+
+// newTaskDocument returns the document indexing a task.
+func newTaskDocument(c *Customer, t *Task) taskDocument {
+	return taskDocument{Name: t.Name, ExternalID: t.ExternalID, CustomerID: c.ID.String(), Archived: t.Archived}
+}
+
+// taskIndexMapping indexes task names word by word and external IDs as a whole, ignoring case
+// so "prj-12" finds PRJ-1234, the customer ID is stored to tell hits of several indexes apart and
+// archived tasks are flagged to leave them out.
+func taskIndexMapping() (mapping.IndexMapping, error) {
+	m := bleve.NewIndexMapping()
+	err := m.AddCustomAnalyzer("external_id", map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating external ID analyzer: %w", err)
+	}
+	name := bleve.NewTextFieldMapping()
+	name.Analyzer = standard.Name
+	externalID := bleve.NewTextFieldMapping()
+	externalID.Analyzer = "external_id"
+	customerID := bleve.NewTextFieldMapping()
+	customerID.Analyzer = keyword.Name
+	customerID.IncludeInAll = false
+	archived := bleve.NewBooleanFieldMapping()
+	archived.IncludeInAll = false
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("name", name)
+	doc.AddFieldMappingsAt("external_id", externalID)
+	doc.AddFieldMappingsAt("customer_id", customerID)
+	doc.AddFieldMappingsAt("archived", archived)
+	m.DefaultMapping = doc
+	return m, nil
+}
+
+// tasksFingerprint identifies the indexed content of a set of tasks.
+func tasksFingerprint(tasks []*Task) (string, error) {
+	sorted := append([]*Task(nil), tasks...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID.String() < sorted[j].ID.String()
+	})
+	h := sha256.New()
+	fmt.Fprintf(h, "schema %d\n", taskIndexSchema)
+	for _, t := range sorted {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return "", fmt.Errorf("encoding task %s: %w", t.ID, err)
+		}
+		h.Write(append(data, '\n'))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// openTaskIndex returns the task index of a customer holding the given tasks, a stored one is
+// reused as long as it was built from the same tasks with the same schema, s.mu must be held.
+func (s *Store) openTaskIndex(c *Customer, tasks []*Task) (bleve.Index, error) {
+	fingerprint, err := tasksFingerprint(tasks)
+	if err != nil {
+		return nil, err
+	}
+	path := taskIndexPath(s.root, c.ID)
+	if s.indexLock != nil {
+		if index, err := bleve.Open(path); err == nil {
+			stored, err := index.GetInternal(fingerprintKey)
+			if err == nil && string(stored) == fingerprint {
+				return index, nil
+			}
+			index.Close()
+		}
+	}
+	return s.buildTaskIndex(c, tasks, fingerprint)
+}
+
+// buildTaskIndex creates the task index of a customer from scratch, on disk when the store holds
+// the index folder and in memory otherwise, s.mu must be held.
+func (s *Store) buildTaskIndex(c *Customer, tasks []*Task, fingerprint string) (bleve.Index, error) {
+	m, err := taskIndexMapping()
+	if err != nil {
+		return nil, err
+	}
+	var index bleve.Index
+	if s.indexLock != nil {
+		path := taskIndexPath(s.root, c.ID)
+		if err := os.RemoveAll(path); err != nil {
+			return nil, fmt.Errorf("removing task index of customer %s: %w", c.Name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("could not create directory %s: %w", filepath.Dir(path), err)
+		}
+		index, err = bleve.New(path, m)
+	} else {
+		index, err = bleve.NewMemOnly(m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating bleve index for customer %s: %w", c.Name, err)
+	}
+	batch := index.NewBatch()
+	for _, t := range tasks {
+		if err := batch.Index(t.ID.String(), newTaskDocument(c, t)); err != nil {
+			index.Close()
+			return nil, fmt.Errorf("indexing task %s for customer %s: %w", t.Name, c.Name, err)
+		}
+	}
+	batch.SetInternal(fingerprintKey, []byte(fingerprint))
+	if err := index.Batch(batch); err != nil {
+		index.Close()
+		return nil, fmt.Errorf("indexing tasks for customer %s: %w", c.Name, err)
+	}
+	return index, nil
+}
+
+// refreshTaskIndex rebuilds the task index of a customer if its tasks changed since it was built.
+func (s *Store) refreshTaskIndex(c *Customer, tasks []*Task) error {
+	fingerprint, err := tasksFingerprint(tasks)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index, ok := s.taskIndex[c.ID]; ok {
+		if stored, err := index.GetInternal(fingerprintKey); err == nil && string(stored) == fingerprint {
+			return nil
+		}
+		index.Close()
+		delete(s.taskIndex, c.ID)
+	}
+	index, err := s.buildTaskIndex(c, tasks, fingerprint)
+	if err != nil {
+		return err
+	}
+	s.taskIndex[c.ID] = index
+	return nil
+}
+
+// removeTaskIndex drops the task index of a deleted customer, s.mu must be held.
+func (s *Store) removeTaskIndex(id uuid.UUID) error {
+	if index, ok := s.taskIndex[id]; ok {
+		index.Close()
+		delete(s.taskIndex, id)
+	}
+	if s.indexLock == nil {
+		return nil
+	}
+	if err := os.RemoveAll(taskIndexPath(s.root, id)); err != nil {
+		return fmt.Errorf("removing task index of customer %s: %w", id, err)
+	}
+	return nil
+}
+
+// Reindex throws away every search index and builds them again from the data.
+func (s *Store) Reindex() error {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, index := range s.taskIndex {
+		index.Close()
+		delete(s.taskIndex, id)
+	}
+	if err := s.closeComments(true); err != nil {
+		return err
+	}
+	if s.indexLock != nil {
+		if err := os.RemoveAll(filepath.Join(indexPath(s.root), "tasks")); err != nil {
+			return fmt.Errorf("removing task indexes: %w", err)
+		}
+	}
+	return s.load()
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"os"
+	"testing"
+)
+
+func TestStore_TaskIndex(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root, Options{VersionControl: true})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	customer := NewCustomer("Test Customer")
+	if err := s.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := s.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	for _, name := range []string{"design", "build"} {
+		if err := ct.AddTask(&Task{ID: uuid.New(), Customer: customer, Name: name}); err != nil {
+			t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+		}
+	}
+	if err := s.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	if _, err := os.Stat(taskIndexPath(root, customer.ID)); err != nil || s.IndexesInMemory() {
+		t.Fatalf("task index not stored under the root: %v", err)
+	}
+	wt, err := s.git.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := wt.Status(); err != nil || !status.IsClean() {
+		t.Errorf("git status = %v, %v, want the indexes ignored", status, err)
+	}
+
+	// another process would not get the index lock either
+	other, err := Open(root, Options{})
+	if err != nil {
+		t.Fatalf("Open() while in use error = %v", err)
+	}
+	if !other.IndexesInMemory() {
+		t.Errorf("second store holds the index lock, want its indexes in memory")
+	}
+	if n, err := other.taskIndex[customer.ID].DocCount(); err != nil || n != 2 {
+		t.Errorf("in memory DocCount() = %d, %v, want 2", n, err)
+	}
+	other.Close()
+	s.Close()
+
+	s, err = Open(root, Options{})
+	if err != nil {
+		t.Fatalf("Open() again error = %v", err)
+	}
+	defer s.Close()
+	stamp := []byte("kept")
+	if err := s.taskIndex[customer.ID].SetInternal([]byte("stamp"), stamp); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reload(); err != nil {
+		t.Fatalf("Store.reload() error = %v", err)
+	}
+	if got, _ := s.taskIndex[customer.ID].GetInternal([]byte("stamp")); string(got) != "kept" {
+		t.Errorf("stored index was rebuilt although the tasks did not change")
+	}
+	if err := s.Reindex(); err != nil {
+		t.Fatalf("Store.Reindex() error = %v", err)
+	}
+	if got, _ := s.taskIndex[customer.ID].GetInternal([]byte("stamp")); got != nil {
+		t.Errorf("Store.Reindex() kept the stored index")
+	}
+	if n, err := s.taskIndex[customer.ID].DocCount(); err != nil || n != 2 {
+		t.Errorf("DocCount() after Store.Reindex() = %d, %v, want 2", n, err)
+	}
+}
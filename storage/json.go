@@ -0,0 +1,409 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JSONBackend stores everything as a tree of JSON files under a root folder:
+//
+//	root/customers/{customerID}/metadata.json
+//	root/customers/{customerID}/tasks.json
+//	root/tasks/{customerID}/{year}/{month}/{day}/{entryID}.json
+//
+// Roots written before entries were kept per customer have them right under root/tasks, Open
+// moves them, see migrateEntryLayout.
+type JSONBackend struct {
+	root string
+
+	mu    sync.Mutex           // guards paths
+	paths map[uuid.UUID]string // where entries seen by this backend were last stored
+}
+
+// NewJSONBackend returns a backend storing JSON files under root.
+func NewJSONBackend(root string) *JSONBackend {
+	return &JSONBackend{root: root, paths: map[uuid.UUID]string{}}
+}
+
+// entryPath returns the file an entry is stored in.
+func (b *JSONBackend) entryPath(e *Entry) string {
+	ref := e.reference()
+	return filepath.Join(dayPath(EntriesSavePath(b.root, &Customer{ID: ref.CustomerID}), e.StartTS), e.ID.String()+".json")
+}
+
+// SaveCustomer implements Backend.
+func (b *JSONBackend) SaveCustomer(c *Customer) error {
+	return c.save(b.root)
+}
+
+// LoadCustomer implements Backend.
+func (b *JSONBackend) LoadCustomer(id uuid.UUID) (*Customer, error) {
+	return LoadCustomer(b.root, id)
+}
+
+// LoadCustomers implements Backend.
+func (b *JSONBackend) LoadCustomers() ([]Customer, error) {
+	return LoadAllCustomers(b.root)
+}
+
+// DeleteCustomer implements Backend.
+func (b *JSONBackend) DeleteCustomer(id uuid.UUID) error {
+	unlock, err := lockRoot(b.root, true, DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	c := &Customer{ID: id}
+	if err := os.RemoveAll(EntriesSavePath(b.root, c)); err != nil {
+		return fmt.Errorf("removing customer entries: %w", err)
+	}
+	if err := os.RemoveAll(c.SavePath(b.root)); err != nil {
+		return fmt.Errorf("removing customer folder: %w", err)
+	}
+	return nil
+}
+
+// SaveTasks implements Backend.
+func (b *JSONBackend) SaveTasks(ct *CustomerTasks) error {
+	return ct.save(b.root)
+}
+
+// LoadTasks implements Backend.
+func (b *JSONBackend) LoadTasks(c *Customer) (*CustomerTasks, error) {
+	return LoadTasks(b.root, c)
+}
+
+// SaveEntry implements Backend, if the entry was seen in another day folder that file is removed.
+func (b *JSONBackend) SaveEntry(e *Entry) error {
+	unlock, err := lockRoot(b.root, true, DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := e.save(b.root); err != nil {
+		return err
+	}
+	entryPath := b.entryPath(e)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if previous, ok := b.paths[e.ID]; ok && previous != entryPath {
+		if err := os.Remove(previous); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing previous entry file: %w", err)
+		}
+	}
+	b.paths[e.ID] = entryPath
+	return nil
+}
+
+// SaveEntries implements Backend, entries are saved one by one so a failure can leave
+// the first ones saved.
+func (b *JSONBackend) SaveEntries(entries ...*Entry) error {
+	for _, e := range entries {
+		if err := b.SaveEntry(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteEntry implements Backend.
+func (b *JSONBackend) DeleteEntry(e *Entry) error {
+	unlock, err := lockRoot(b.root, true, DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entryPath := b.entryPath(e)
+	b.mu.Lock()
+	if previous, ok := b.paths[e.ID]; ok {
+		entryPath = previous
+	}
+	delete(b.paths, e.ID)
+	b.mu.Unlock()
+	if err := os.Remove(entryPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("entry %s: %w", e.ID, ErrNotFound)
+		}
+		return fmt.Errorf("removing entry file: %w", err)
+	}
+	return nil
+}
+
+// LoadEntries implements Backend, it only walks the day folders that can hold entries in range.
+func (b *JSONBackend) LoadEntries(customerID uuid.UUID, from, to time.Time) ([]*Entry, error) {
+	// a single lock over the whole walk gives a consistent snapshot
+	unlock, err := lockRoot(b.root, false, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return collectEntries(b, customerID, from, to)
+}
+
+// WalkEntries implements Backend, it reads a day folder at a time and only holds back the
+// entries a later folder could still precede.
+func (b *JSONBackend) WalkEntries(ctx context.Context, customerID uuid.UUID, from, to time.Time, fn func(e *Entry) error) error {
+	days, err := b.entryDays(customerID, from, to)
+	if err != nil {
+		return err
+	}
+	var pending []*Entry
+	// yield passes on the pending entries started before until, all of them if until is zero
+	yield := func(until time.Time) error {
+		n := 0
+		for ; n < len(pending) && (until.IsZero() || pending[n].StartTS.Before(until)); n++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(pending[n]); err != nil {
+				return err
+			}
+		}
+		pending = pending[n:]
+		return nil
+	}
+	for _, day := range days {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dayEntries, err := b.loadDay(day.path)
+		if err != nil {
+			return err
+		}
+		for _, e := range dayEntries {
+			if inRange(e.StartTS, from, to) {
+				pending = append(pending, e)
+			}
+		}
+		sort.Stable(Entries(pending))
+		// later folders only hold entries started after the next day, give or take folderSlack
+		if err := yield(day.start.AddDate(0, 0, 1).Add(-folderSlack)); err != nil {
+			return walkResult(err)
+		}
+	}
+	return walkResult(yield(time.Time{}))
+}
+
+// entryDays lists the day folders of a customer which may hold entries started in [from, to).
+func (b *JSONBackend) entryDays(customerID uuid.UUID, from, to time.Time) ([]dayFolder, error) {
+	unlock, err := lockRoot(b.root, false, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return dayFolders(EntriesSavePath(b.root, &Customer{ID: customerID}), from, to)
+}
+
+// loadDay loads the entries of a single day folder under the shared lock.
+func (b *JSONBackend) loadDay(path string) ([]*Entry, error) {
+	unlock, err := lockRoot(b.root, false, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	entries, err := b.loadPathEntries(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// removed since the folders were listed
+		return nil, nil
+	}
+	return entries, err
+}
+
+// LastEntry implements Backend.
+func (b *JSONBackend) LastEntry(customerID uuid.UUID) (*Entry, error) {
+	unlock, err := lockRoot(b.root, false, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	days, err := dayFolders(EntriesSavePath(b.root, &Customer{ID: customerID}), time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for i := len(days) - 1; i >= 0; i-- {
+		dayEntries, err := b.loadPathEntries(days[i].path)
+		if err != nil {
+			return nil, err
+		}
+		if len(dayEntries) > 0 {
+			return dayEntries[len(dayEntries)-1], nil
+		}
+	}
+	return nil, fmt.Errorf("no entries for customer %s: %w", customerID, ErrNotFound)
+}
+
+// Close implements Backend.
+func (b *JSONBackend) Close() error {
+	return nil
+}
+
+// folderSlack accounts for folders being named after the entry start in its own timezone.
+const folderSlack = 24 * time.Hour
+
+// overlaps tells if the period [start, end) padded by folderSlack overlaps [from, to).
+func overlaps(start, end, from, to time.Time) bool {
+	if !from.IsZero() && !end.Add(folderSlack).After(from) {
+		return false
+	}
+	if !to.IsZero() && !start.Add(-folderSlack).Before(to) {
+		return false
+	}
+	return true
+}
+
+// numericDirs returns the sorted numeric names of the folders in dir, dir not existing means no folders.
+func numericDirs(dir string) ([]int, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading directory %s: %w", dir, err)
+	}
+	var numbers []int
+	for _, d := range dirEntries {
+		if !d.IsDir() {
+			continue
+		}
+		n, err := strconv.Atoi(d.Name())
+		if err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// dayFolder is a folder holding the entries started on a day, start is the day midnight in UTC.
+type dayFolder struct {
+	path  string
+	start time.Time
+}
+
+// dayFolders returns, in chronological order, the day folders under base which may hold entries started in [from, to).
+func dayFolders(base string, from, to time.Time) ([]dayFolder, error) {
+	var days []dayFolder
+	years, err := numericDirs(base)
+	if err != nil {
+		return nil, err
+	}
+	for _, year := range years {
+		yearStart := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		if !overlaps(yearStart, yearStart.AddDate(1, 0, 0), from, to) {
+			continue
+		}
+		yearPath := filepath.Join(base, dateComp(year))
+		months, err := numericDirs(yearPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, month := range months {
+			monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			if !overlaps(monthStart, monthStart.AddDate(0, 1, 0), from, to) {
+				continue
+			}
+			monthPath := filepath.Join(yearPath, dateComp(month))
+			monthDays, err := numericDirs(monthPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, day := range monthDays {
+				dayStart := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+				if !overlaps(dayStart, dayStart.AddDate(0, 0, 1), from, to) {
+					continue
+				}
+				days = append(days, dayFolder{path: filepath.Join(monthPath, dateComp(day)), start: dayStart})
+			}
+		}
+	}
+	return days, nil
+}
+
+// loadPathEntries loads all entries stored as json files in entriesPath sorted by start date,
+// their task references are left for the Store to resolve.
+func (b *JSONBackend) loadPathEntries(entriesPath string) ([]*Entry, error) {
+	// now load all entries for that day which are in the form of json files
+	entries, err := os.ReadDir(entriesPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading day directory %s: %w", entriesPath, err)
+	}
+	var dayEntries = make([]*Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		entryPath := filepath.Join(entriesPath, entry.Name())
+		f, err := os.Open(entryPath)
+		if err != nil {
+			return nil, fmt.Errorf("open entry file: %w", err)
+		}
+		var e Entry
+		err = json.NewDecoder(f).Decode(&e)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode entry file %s: %w", entryPath, err)
+		}
+		b.mu.Lock()
+		b.paths[e.ID] = entryPath
+		b.mu.Unlock()
+		dayEntries = append(dayEntries, &e)
+	}
+	sort.Sort(Entries(dayEntries))
+	return dayEntries, nil
+}
+
+// migrateEntryLayout moves the entries of data roots written before entries were kept per
+// customer, from root/tasks/{year}/{month}/{day} to the folder of the customer each one points
+// to. The root lock must be held exclusively.
+func migrateEntryLayout(root string) error {
+	legacy := filepath.Join(root, "tasks")
+	// customer folders are named after UUIDs, only the legacy year folders are numeric
+	days, err := dayFolders(legacy, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, day := range days {
+		files, err := os.ReadDir(day.path)
+		if err != nil {
+			return fmt.Errorf("reading day directory %s: %w", day.path, err)
+		}
+		rel, err := filepath.Rel(legacy, day.path)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+				continue
+			}
+			from := filepath.Join(day.path, f.Name())
+			data, err := os.ReadFile(from)
+			if err != nil {
+				return fmt.Errorf("reading entry file %s: %w", from, err)
+			}
+			var e Entry
+			if err := json.Unmarshal(data, &e); err != nil {
+				return fmt.Errorf("decode entry file %s: %w", from, err)
+			}
+			dir := filepath.Join(EntriesSavePath(root, &Customer{ID: e.reference().CustomerID}), rel)
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return fmt.Errorf("could not create entry day folder %s: %w", dir, err)
+			}
+			to := filepath.Join(dir, f.Name())
+			if err := os.Rename(from, to); err != nil {
+				return fmt.Errorf("moving entry file %s: %w", from, err)
+			}
+		}
+		removeEmptyDirs(legacy, day.path)
+	}
+	return nil
+}
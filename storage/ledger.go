@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrSealed is returned when changing an entry started within a sealed period.
+var ErrSealed = errors.New("period is sealed")
+
+// Ledger operations.
+const (
+	ledgerSave   = "save"
+	ledgerDelete = "delete"
+	ledgerSeal   = "seal"
+	ledgerMerge  = "merge"
+)
+
+// ledgerRecord is a line of a customer ledger, every record holds the hash of the one before it
+// so rewriting any of them breaks the chain from there on.
+type ledgerRecord struct {
+	Seq       uint64    `json:"seq"`
+	Op        string    `json:"op"`
+	EntryID   uuid.UUID `json:"entry_id"`   // zero for seals and merges
+	EntryHash string    `json:"entry_hash"` // hash of the saved entry, of the sealed entries for seals or of the merged head for merges
+	Start     time.Time `json:"start"`      // start of the saved entry or of the sealed period
+	End       time.Time `json:"end"`        // end of the sealed period
+	Time      time.Time `json:"time"`
+	Prev      string    `json:"prev"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// computeHash returns the hash of the record, which covers every field but Hash itself.
+func (r ledgerRecord) computeHash() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashEntry returns the hash of an entry as it is saved, chain fields included.
+func hashEntry(e *Entry) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("encoding entry %s: %w", e.ID, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// hashSealed returns the hash covering the given entry hashes, in the order of their entry IDs.
+func hashSealed(hashes map[uuid.UUID]string) string {
+	ids := make([]uuid.UUID, 0, len(hashes))
+	for id := range hashes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+	h := sha256.New()
+	for _, id := range ids {
+		fmt.Fprintf(h, "%s %s\n", id, hashes[id])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ledger is the hash chain of the changes made to the entries of a customer, it is an append
+// only file of JSON lines under root/ledger. Machines syncing the same customer each extend
+// their own chain, see mergeLedger for how they are put back together.
+type ledger struct {
+	path    string
+	records []ledgerRecord
+}
+
+// ledgerPath returns the ledger file of a customer.
+func ledgerPath(root string, customerID uuid.UUID) string {
+	return filepath.Join(root, "ledger", customerID.String()+".jsonl")
+}
+
+// loadLedger reads the ledger of a customer, a missing file is an empty ledger.
+func loadLedger(root string, customerID uuid.UUID) (*ledger, error) {
+	l := &ledger{path: ledgerPath(root, customerID)}
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, fmt.Errorf("open ledger: %w", err)
+	}
+	defer f.Close()
+	if l.records, err = decodeLedger(f); err != nil {
+		return nil, fmt.Errorf("ledger %s: %w", l.path, err)
+	}
+	return l, nil
+}
+
+// decodeLedger reads the records of a ledger, one JSON line each.
+func decodeLedger(r io.Reader) ([]ledgerRecord, error) {
+	var records []ledgerRecord
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var r ledgerRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("decode record %d: %w", len(records)+1, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return records, nil
+}
+
+// encodeLedger writes records the way ledger.append does.
+func encodeLedger(w io.Writer, records []ledgerRecord) error {
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("encode ledger record: %w", err)
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// head returns the sequence number and hash of the last record.
+func (l *ledger) head() (uint64, string) {
+	if len(l.records) == 0 {
+		return 0, ""
+	}
+	last := l.records[len(l.records)-1]
+	return last.Seq, last.Hash
+}
+
+// link numbers the record after the ones given and chains it to the last of them.
+func (l *ledger) link(r *ledgerRecord, previous []ledgerRecord) {
+	seq, prev := l.head()
+	if len(previous) > 0 {
+		seq, prev = previous[len(previous)-1].Seq, previous[len(previous)-1].Hash
+	}
+	r.Seq = seq + 1
+	r.Prev = prev
+	r.Time = time.Now().UTC()
+	r.Hash = r.computeHash()
+}
+
+// append writes records already linked by link at the end of the ledger.
+func (l *ledger) append(records ...ledgerRecord) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), os.ModePerm); err != nil {
+		return fmt.Errorf("could not create directory %s: %w", filepath.Dir(l.path), err)
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("open ledger: %w", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := encodeLedger(w, records); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write ledger: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync ledger: %w", err)
+	}
+	l.records = append(l.records, records...)
+	return nil
+}
+
+// state returns the last save or delete record of every entry in the ledger.
+func (l *ledger) state() map[uuid.UUID]*ledgerRecord {
+	last := map[uuid.UUID]*ledgerRecord{}
+	for i := range l.records {
+		if r := &l.records[i]; r.Op == ledgerSave || r.Op == ledgerDelete {
+			last[r.EntryID] = r
+		}
+	}
+	return last
+}
+
+// sealing returns the seal covering t or nil if none does.
+func (l *ledger) sealing(t time.Time) *ledgerRecord {
+	for i := range l.records {
+		r := &l.records[i]
+		if r.Op == ledgerSeal && inRange(t, r.Start, r.End) {
+			return r
+		}
+	}
+	return nil
+}
+
+// mergeLedger merges two versions of a ledger extended on both sides since they last synced.
+// Records after the common ones are kept as they are on the local side and the ones made there
+// are chained after them, renumbered and rehashed, so both machines end up with a single chain
+// covering every entry. A merge record closes them holding the hash the other side had as its
+// head, so seals handed out over there can still be traced.
+func mergeLedger(ours, theirs []byte, r *MergeResult) ([]byte, error) {
+	local, err := decodeLedger(bytes.NewReader(ours))
+	if err != nil {
+		return nil, fmt.Errorf("decoding ledger: %w", err)
+	}
+	remote, err := decodeLedger(bytes.NewReader(theirs))
+	if err != nil {
+		return nil, fmt.Errorf("decoding ledger: %w", err)
+	}
+	common := 0
+	for common < len(local) && common < len(remote) && local[common].Hash == remote[common].Hash {
+		common++
+	}
+	switch {
+	case common == len(remote):
+		return ours, nil
+	case common == len(local):
+		return theirs, nil
+	}
+	l := &ledger{records: append([]ledgerRecord(nil), local...)}
+	for _, record := range remote[common:] {
+		seq, prev := l.head()
+		record.Seq, record.Prev = seq+1, prev
+		record.Hash = record.computeHash()
+		l.records = append(l.records, record)
+	}
+	closing := ledgerRecord{Op: ledgerMerge, EntryHash: remote[len(remote)-1].Hash}
+	l.link(&closing, nil)
+	l.records = append(l.records, closing)
+	r.Resolved = append(r.Resolved, fmt.Sprintf("chained the %d records made there after the %d made here", len(remote)-common, len(local)-common))
+	var buf bytes.Buffer
+	if err := encodeLedger(&buf, l.records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
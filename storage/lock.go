@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLocked is returned when the data root lock cannot be acquired before the timeout.
+var ErrLocked = errors.New("data root is locked")
+
+// DefaultLockTimeout is how long mutating functions wait for the data root lock.
+var DefaultLockTimeout = 5 * time.Second
+
+// lockFileName is the file in the data root holding the advisory lock and the PID of its last holder.
+const lockFileName = ".lock"
+
+// lockRetryInterval is how often a busy lock is retried until the timeout.
+const lockRetryInterval = 50 * time.Millisecond
+
+// rootLock is the advisory lock of a data root as held by this process, other processes
+// see a shared lock while only readers are inside and an exclusive one while a writer is.
+// Within the process it is reentrant, exclusive holders may take it again in either mode.
+type rootLock struct {
+	mu        sync.Mutex
+	path      string
+	f         *os.File
+	shared    int
+	exclusive int
+}
+
+// rootLocks holds the locks of every data root this process touched, keyed by absolute root path.
+var rootLocks = struct {
+	sync.Mutex
+	m map[string]*rootLock
+}{m: map[string]*rootLock{}}
+
+// lockRoot takes the data root lock, exclusive for writers and shared for readers, and returns the
+// function releasing it. It fails with ErrLocked, naming the holder PID, if timeout elapses first.
+func lockRoot(root string, exclusive bool, timeout time.Duration) (func(), error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolving data root %s: %w", root, err)
+	}
+	rootLocks.Lock()
+	l, ok := rootLocks.m[abs]
+	if !ok {
+		l = &rootLock{path: filepath.Join(abs, lockFileName)}
+		rootLocks.m[abs] = l
+	}
+	rootLocks.Unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.acquire(exclusive, timeout); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.release(exclusive)
+		})
+	}, nil
+}
+
+// acquire takes the lock in the given mode, l.mu must be held.
+func (l *rootLock) acquire(exclusive bool, timeout time.Duration) error {
+	switch {
+	case l.exclusive > 0, !exclusive && l.shared > 0:
+		// the file lock already covers the requested mode
+	default:
+		if l.f == nil {
+			if err := os.MkdirAll(filepath.Dir(l.path), os.ModePerm); err != nil {
+				return fmt.Errorf("could not create directory %s: %w", filepath.Dir(l.path), err)
+			}
+			f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+			if err != nil {
+				return fmt.Errorf("open lock file: %w", err)
+			}
+			l.f = f
+		}
+		if err := l.wait(exclusive, timeout); err != nil {
+			if l.shared == 0 && l.exclusive == 0 {
+				l.f.Close()
+				l.f = nil
+			}
+			return err
+		}
+		l.writePID()
+	}
+	if exclusive {
+		l.exclusive++
+	} else {
+		l.shared++
+	}
+	return nil
+}
+
+// wait retries the file lock until it is granted or timeout elapses.
+func (l *rootLock) wait(exclusive bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLockFile(l.f, exclusive)
+		if err != nil {
+			return fmt.Errorf("locking %s: %w", l.path, err)
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			mode := "shared"
+			if exclusive {
+				mode = "exclusive"
+			}
+			if pid := l.holderPID(); pid != 0 {
+				return fmt.Errorf("waited %s for %s lock on %s held by PID %d: %w", timeout, mode, l.path, pid, ErrLocked)
+			}
+			return fmt.Errorf("waited %s for %s lock on %s: %w", timeout, mode, l.path, ErrLocked)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// release gives back one hold in the given mode, l.mu must be held.
+func (l *rootLock) release(exclusive bool) {
+	if exclusive {
+		l.exclusive--
+	} else {
+		l.shared--
+	}
+	switch {
+	case l.exclusive > 0:
+	case l.shared > 0:
+		if exclusive {
+			// downgrading can only fail if the file went away, the shared holders keep going regardless
+			tryLockFile(l.f, false)
+		}
+	default:
+		unlockFile(l.f)
+		l.f.Close()
+		l.f = nil
+	}
+}
+
+// writePID records this process as the lock holder, it is only informative.
+func (l *rootLock) writePID() {
+	if err := l.f.Truncate(0); err != nil {
+		return
+	}
+	l.f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+}
+
+// holderPID returns the PID of the last process that took the lock or 0 if unknown.
+func (l *rootLock) holderPID() int {
+	content, err := os.ReadFile(l.path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLockHelperProcess is not a real test, it holds the lock of a data root on behalf of TestLockRoot.
+func TestLockHelperProcess(t *testing.T) {
+	root := os.Getenv("BAC_LOCK_HELPER_ROOT")
+	if root == "" {
+		t.Skip("only run as a helper process")
+	}
+	unlock, err := lockRoot(root, os.Getenv("BAC_LOCK_HELPER_MODE") == "exclusive", time.Second)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("locked")
+	// hold the lock until the parent closes stdin
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	unlock()
+	os.Exit(0)
+}
+
+// holdLock starts a process holding the lock of root and returns it once the lock is taken.
+func holdLock(t *testing.T, root, mode string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+	cmd.Env = append(os.Environ(), "BAC_LOCK_HELPER_ROOT="+root, "BAC_LOCK_HELPER_MODE="+mode)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("stdin pipe: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("stdout pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting helper: %v", err)
+	}
+	t.Cleanup(func() {
+		stdin.Close()
+		cmd.Wait()
+	})
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "locked" {
+		t.Fatalf("helper did not take the lock: %q %v", line, err)
+	}
+	return cmd
+}
+
+func TestLockRoot(t *testing.T) {
+	tests := []struct {
+		name          string
+		held          string
+		exclusive     bool
+		wantErr       bool
+		wantHolderPID bool
+	}{
+		{name: "shared with shared", held: "shared", exclusive: false},
+		{name: "exclusive with shared", held: "shared", exclusive: true, wantErr: true},
+		{name: "shared with exclusive", held: "exclusive", exclusive: false, wantErr: true, wantHolderPID: true},
+		{name: "exclusive with exclusive", held: "exclusive", exclusive: true, wantErr: true, wantHolderPID: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			helper := holdLock(t, root, tt.held)
+			unlock, err := lockRoot(root, tt.exclusive, 200*time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lockRoot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				unlock()
+				return
+			}
+			if !errors.Is(err, ErrLocked) {
+				t.Errorf("lockRoot() error = %v, want %v", err, ErrLocked)
+			}
+			if pid := strconv.Itoa(helper.Process.Pid); tt.wantHolderPID && !strings.Contains(err.Error(), "PID "+pid) {
+				t.Errorf("lockRoot() error = %v, want it to name PID %s", err, pid)
+			}
+		})
+	}
+}
+
+func TestStore_HonorsLock(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root, Options{LockTimeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	// the lock is reentrant within the process, held locks cover the calls made under them
+	unlock, err := s.lock(true)
+	if err != nil {
+		t.Fatalf("Store.lock() error = %v", err)
+	}
+	if err := s.AddCustomer(NewCustomer("Test Customer")); err != nil {
+		t.Errorf("Store.AddCustomer() under lock error = %v", err)
+	}
+	unlock()
+
+	holdLock(t, root, "shared")
+	if err := s.AddCustomer(NewCustomer("Other Customer")); !errors.Is(err, ErrLocked) {
+		t.Errorf("Store.AddCustomer() error = %v, want %v", err, ErrLocked)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package storage
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockRegion is the byte range locked, it lies past the content so the holder PID stays readable.
+var lockRegion = windows.Overlapped{OffsetHigh: 1}
+
+// tryLockFile takes or converts the lock on f without blocking, it reports false if another process holds it.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	h := windows.Handle(f.Fd())
+	// LockFileEx does not convert locks, drop the current mode first
+	ol := lockRegion
+	windows.UnlockFileEx(h, 0, 1, 0, &ol)
+	var flags uint32 = windows.LOCKFILE_FAIL_IMMEDIATELY
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	ol = lockRegion
+	err := windows.LockFileEx(h, flags, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		if exclusive {
+			// keep at least the shared mode a failed upgrade started from
+			ol = lockRegion
+			windows.LockFileEx(h, windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+		}
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockFile releases the lock on f.
+func unlockFile(f *os.File) error {
+	ol := lockRegion
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// RenameCustomer renames a customer, every cached task gets the new name too.
+func (s *Store) RenameCustomer(id uuid.UUID, name string) (*Customer, error) {
+	var previous string
+	return s.updateCustomer(id, func(c *Customer) string {
+		previous, c.Name = c.Name, name
+		return fmt.Sprintf("rename customer %s to %s", previous, name)
+	})
+}
+
+// ArchiveCustomer hides a customer from listings and searches, or brings it back.
+func (s *Store) ArchiveCustomer(id uuid.UUID, archived bool) (*Customer, error) {
+	return s.updateCustomer(id, func(c *Customer) string {
+		c.Archived = archived
+		if archived {
+			return "archive customer " + c.Name
+		}
+		return "unarchive customer " + c.Name
+	})
+}
+
+// updateCustomer applies update to a copy of a customer, saves it and caches it, update returns
+// the commit message.
+func (s *Store) updateCustomer(id uuid.UUID, update func(c *Customer) string) (*Customer, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	c, err := s.Customer(id)
+	if err != nil {
+		return nil, err
+	}
+	message := update(c)
+	if err := s.backend.SaveCustomer(c); err != nil {
+		return nil, fmt.Errorf("saving customer %s: %w", c.Name, err)
+	}
+	s.mu.Lock()
+	s.setCustomer(c)
+	s.mu.Unlock()
+	if err := s.commit("%s", message); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// RenameTask renames a task of a customer.
+func (s *Store) RenameTask(customerID, taskID uuid.UUID, name string) (*Task, error) {
+	var previous string
+	return s.updateTask(customerID, taskID, func(t *Task) string {
+		previous, t.Name = t.Name, name
+		return fmt.Sprintf("rename task %s to %s", previous, name)
+	})
+}
+
+// ArchiveTask hides a task from listings and searches, or brings it back.
+func (s *Store) ArchiveTask(customerID, taskID uuid.UUID, archived bool) (*Task, error) {
+	return s.updateTask(customerID, taskID, func(t *Task) string {
+		t.Archived = archived
+		if archived {
+			return "archive task " + t.Name
+		}
+		return "unarchive task " + t.Name
+	})
+}
+
+// updateTask applies update to a task of a customer and saves its tasks, update returns the
+// commit message.
+func (s *Store) updateTask(customerID, taskID uuid.UUID, update func(t *Task) string) (*Task, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	ct, err := s.LoadTasks(customerID)
+	if err != nil {
+		return nil, err
+	}
+	t := ct.task(taskID)
+	if t == nil {
+		return nil, fmt.Errorf("task %s of customer %s: %w", taskID, ct.Customer.Name, ErrNotFound)
+	}
+	message := update(t)
+	if err := s.saveTasks(ct, message+" for "+ct.Customer.Name); err != nil {
+		return nil, err
+	}
+	return s.Task(customerID, taskID)
+}
+
+// MoveTask moves a task to another customer along with its entries, they are recorded as deleted
+// from the chain of the previous customer and saved in the one of the new. Entries in a sealed
+// period of either customer prevent the move.
+func (s *Store) MoveTask(customerID, taskID, toCustomerID uuid.UUID) (*Task, error) {
+	if customerID == toCustomerID {
+		return nil, fmt.Errorf("task %s already belongs to customer %s", taskID, customerID)
+	}
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	from, err := s.LoadTasks(customerID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.LoadTasks(toCustomerID)
+	if err != nil {
+		return nil, err
+	}
+	t := from.task(taskID)
+	if t == nil {
+		return nil, fmt.Errorf("task %s of customer %s: %w", taskID, from.Customer.Name, ErrNotFound)
+	}
+	moved := *t
+	moved.Customer = to.Customer
+	kept := from.Tasks[:0]
+	for _, task := range from.Tasks {
+		if task.ID != taskID {
+			kept = append(kept, task)
+		}
+	}
+	from.Tasks = kept
+	to.Tasks = append(to.Tasks, &moved)
+
+	entries, err := s.backend.LoadEntries(customerID, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("loading entries of customer %s: %w", from.Customer.Name, err)
+	}
+	var previous, next []*Entry
+	for _, e := range entries {
+		if e.reference().TaskID != taskID {
+			continue
+		}
+		e.Task = t
+		n := *e
+		n.Task = &moved
+		previous, next = append(previous, e), append(next, &n)
+	}
+	// both chains are checked before anything is written
+	recordDeleted, err := s.chain(ledgerDelete, previous...)
+	if err != nil {
+		return nil, err
+	}
+	recordSaved, err := s.chain(ledgerSave, next...)
+	if err != nil {
+		return nil, err
+	}
+	// the task exists in its new place before its entries move and is only removed after
+	if err := s.backend.SaveTasks(to); err != nil {
+		return nil, fmt.Errorf("saving tasks for customer %s: %w", to.Customer.Name, err)
+	}
+	for _, e := range previous {
+		if err := s.backend.DeleteEntry(e); err != nil {
+			return nil, fmt.Errorf("deleting entry %s: %w", e.ID, err)
+		}
+	}
+	if err := s.backend.SaveEntries(next...); err != nil {
+		return nil, fmt.Errorf("saving moved entries: %w", err)
+	}
+	if err := s.backend.SaveTasks(from); err != nil {
+		return nil, fmt.Errorf("saving tasks for customer %s: %w", from.Customer.Name, err)
+	}
+	if err := recordDeleted(); err != nil {
+		return nil, err
+	}
+	if err := recordSaved(); err != nil {
+		return nil, err
+	}
+	// running entries now belong to the new customer
+	if err := s.updateActive(false, next...); err != nil {
+		return nil, err
+	}
+	for _, ct := range []*CustomerTasks{from, to} {
+		if err := s.refreshTaskIndex(ct.Customer, ct.Tasks); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.setTasks(ct.Customer, ct.Tasks)
+		s.mu.Unlock()
+	}
+	if err := s.updateComments(true, previous...); err != nil {
+		return nil, err
+	}
+	if err := s.updateComments(false, next...); err != nil {
+		return nil, err
+	}
+	if err := s.commit("move task %s with %d entries from %s to %s", t.Name, len(next), from.Customer.Name, to.Customer.Name); err != nil {
+		return nil, err
+	}
+	return s.Task(toCustomerID, taskID)
+}
+
+// task returns the task with the given ID or nil.
+func (c *CustomerTasks) task(id uuid.UUID) *Task {
+	for _, t := range c.Tasks {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
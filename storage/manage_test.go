@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestStore_RenameCustomer(t *testing.T) {
+	s, task, _ := newChainStore(t)
+	if _, err := s.RenameCustomer(task.Customer.ID, "Renamed"); err != nil {
+		t.Fatalf("Store.RenameCustomer() error = %v", err)
+	}
+	c, err := s.Customer(task.Customer.ID)
+	if err != nil {
+		t.Fatalf("Store.Customer() error = %v", err)
+	}
+	if c.Name != "Renamed" {
+		t.Errorf("Store.Customer() name = %q, want %q", c.Name, "Renamed")
+	}
+	cached, err := s.Task(task.Customer.ID, task.ID)
+	if err != nil {
+		t.Fatalf("Store.Task() error = %v", err)
+	}
+	if cached.Customer.Name != "Renamed" {
+		t.Errorf("Store.Task() customer name = %q, want %q", cached.Customer.Name, "Renamed")
+	}
+	entries, err := s.LoadEntries(EntryFilter{CustomerID: task.Customer.ID})
+	if err != nil {
+		t.Fatalf("Store.LoadEntries() error = %v", err)
+	}
+	for _, e := range entries {
+		if e.Task.Customer.Name != "Renamed" {
+			t.Errorf("entry %s customer name = %q, want %q", e.ID, e.Task.Customer.Name, "Renamed")
+		}
+	}
+}
+
+func TestStore_ArchiveTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		archive  func(s *Store, task *Task) error
+		opts     TaskSearchOptions
+		wantHits int
+	}{
+		{
+			name:     "active",
+			archive:  func(s *Store, task *Task) error { return nil },
+			wantHits: 1,
+		},
+		{
+			name: "archived task",
+			archive: func(s *Store, task *Task) error {
+				_, err := s.ArchiveTask(task.Customer.ID, task.ID, true)
+				return err
+			},
+			wantHits: 0,
+		},
+		{
+			name: "archived task included",
+			archive: func(s *Store, task *Task) error {
+				_, err := s.ArchiveTask(task.Customer.ID, task.ID, true)
+				return err
+			},
+			opts:     TaskSearchOptions{IncludeArchived: true},
+			wantHits: 1,
+		},
+		{
+			name: "unarchived task",
+			archive: func(s *Store, task *Task) error {
+				if _, err := s.ArchiveTask(task.Customer.ID, task.ID, true); err != nil {
+					return err
+				}
+				_, err := s.ArchiveTask(task.Customer.ID, task.ID, false)
+				return err
+			},
+			wantHits: 1,
+		},
+		{
+			name: "archived customer",
+			archive: func(s *Store, task *Task) error {
+				_, err := s.ArchiveCustomer(task.Customer.ID, true)
+				return err
+			},
+			wantHits: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, task, _ := newChainStore(t)
+			if err := tt.archive(s, task); err != nil {
+				t.Fatalf("archiving error = %v", err)
+			}
+			matches, err := s.SearchTasks("task", tt.opts)
+			if err != nil {
+				t.Fatalf("Store.SearchTasks() error = %v", err)
+			}
+			if len(matches) != tt.wantHits {
+				t.Errorf("Store.SearchTasks() = %d matches, want %d", len(matches), tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestStore_MoveTask(t *testing.T) {
+	s, task, entries := newChainStore(t)
+	to := NewCustomer("Other Customer")
+	if err := s.AddCustomer(to); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	moved, err := s.MoveTask(task.Customer.ID, task.ID, to.ID)
+	if err != nil {
+		t.Fatalf("Store.MoveTask() error = %v", err)
+	}
+	if moved.Customer.ID != to.ID {
+		t.Errorf("Store.MoveTask() customer = %s, want %s", moved.Customer.ID, to.ID)
+	}
+	if _, err := s.Task(task.Customer.ID, task.ID); err == nil {
+		t.Error("Store.Task() found the task under its previous customer")
+	}
+	left, err := s.LoadEntries(EntryFilter{CustomerID: task.Customer.ID})
+	if err != nil {
+		t.Fatalf("Store.LoadEntries() error = %v", err)
+	}
+	if len(left) != 0 {
+		t.Errorf("previous customer kept %d entries, want 0", len(left))
+	}
+	got, err := s.LoadEntries(EntryFilter{CustomerID: to.ID})
+	if err != nil {
+		t.Fatalf("Store.LoadEntries() error = %v", err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("new customer has %d entries, want %d", len(got), len(entries))
+	}
+	for i, e := range got {
+		if e.ID != entries[i].ID || e.Task.Customer.ID != to.ID {
+			t.Errorf("entry %d = %s of customer %s, want %s of %s", i, e.ID, e.Task.Customer.ID, entries[i].ID, to.ID)
+		}
+	}
+	for _, id := range []uuid.UUID{task.Customer.ID, to.ID} {
+		if reasons := problemReasons(t, s, id); len(reasons) != 0 {
+			t.Errorf("Store.VerifyChain(%s) = %v, want no problems", id, reasons)
+		}
+	}
+}
+
+func TestStore_MoveTask_sealed(t *testing.T) {
+	s, task, _ := newChainStore(t)
+	to := NewCustomer("Other Customer")
+	if err := s.AddCustomer(to); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	from, until := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.Seal(task.Customer.ID, from, until); err != nil {
+		t.Fatalf("Store.Seal() error = %v", err)
+	}
+	if _, err := s.MoveTask(task.Customer.ID, task.ID, to.ID); !errors.Is(err, ErrSealed) {
+		t.Errorf("Store.MoveTask() error = %v, want %v", err, ErrSealed)
+	}
+	if _, err := s.Task(task.Customer.ID, task.ID); err != nil {
+		t.Errorf("Store.Task() error = %v, want the task left in place", err)
+	}
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps everything in memory, it is meant for tests.
+type MemoryBackend struct {
+	mu        sync.RWMutex // guards the fields below
+	customers map[uuid.UUID]Customer
+	tasks     map[uuid.UUID][]Task
+	entries   map[uuid.UUID]map[uuid.UUID]Entry
+}
+
+// NewMemoryBackend returns an empty in-memory backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		customers: map[uuid.UUID]Customer{},
+		tasks:     map[uuid.UUID][]Task{},
+		entries:   map[uuid.UUID]map[uuid.UUID]Entry{},
+	}
+}
+
+// SaveCustomer implements Backend.
+func (b *MemoryBackend) SaveCustomer(c *Customer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.customers[c.ID] = *c
+	return nil
+}
+
+// LoadCustomer implements Backend.
+func (b *MemoryBackend) LoadCustomer(id uuid.UUID) (*Customer, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	c, ok := b.customers[id]
+	if !ok {
+		return nil, fmt.Errorf("customer %s does not exist: %w", id, ErrNotFound)
+	}
+	return &c, nil
+}
+
+// LoadCustomers implements Backend.
+func (b *MemoryBackend) LoadCustomers() ([]Customer, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	customers := make([]Customer, 0, len(b.customers))
+	for _, c := range b.customers {
+		customers = append(customers, c)
+	}
+	sort.Slice(customers, func(i, j int) bool {
+		return customers[i].ID.String() < customers[j].ID.String()
+	})
+	return customers, nil
+}
+
+// DeleteCustomer implements Backend.
+func (b *MemoryBackend) DeleteCustomer(id uuid.UUID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.customers, id)
+	delete(b.tasks, id)
+	delete(b.entries, id)
+	return nil
+}
+
+// SaveTasks implements Backend.
+func (b *MemoryBackend) SaveTasks(ct *CustomerTasks) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tasks := make([]Task, len(ct.Tasks))
+	for i, t := range ct.Tasks {
+		tasks[i] = *t
+		tasks[i].Customer = nil
+	}
+	b.tasks[ct.Customer.ID] = tasks
+	return nil
+}
+
+// LoadTasks implements Backend.
+func (b *MemoryBackend) LoadTasks(c *Customer) (*CustomerTasks, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ct := &CustomerTasks{
+		Customer: c,
+		Tasks:    make([]*Task, 0, len(b.tasks[c.ID])),
+	}
+	for _, t := range b.tasks[c.ID] {
+		t := t
+		t.Customer = c
+		ct.Tasks = append(ct.Tasks, &t)
+	}
+	return ct, nil
+}
+
+// SaveEntry implements Backend.
+func (b *MemoryBackend) SaveEntry(e *Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stored := *e
+	stored.ref = e.reference()
+	stored.Task = nil
+	if e.EndTs != nil {
+		endTS := *e.EndTs
+		stored.EndTs = &endTS
+	}
+	stored.Tags = append([]string(nil), e.Tags...)
+	customerEntries, ok := b.entries[stored.ref.CustomerID]
+	if !ok {
+		customerEntries = map[uuid.UUID]Entry{}
+		b.entries[stored.ref.CustomerID] = customerEntries
+	}
+	customerEntries[e.ID] = stored
+	return nil
+}
+
+// SaveEntries implements Backend.
+func (b *MemoryBackend) SaveEntries(entries ...*Entry) error {
+	for _, e := range entries {
+		if err := b.SaveEntry(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteEntry implements Backend.
+func (b *MemoryBackend) DeleteEntry(e *Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	customerEntries := b.entries[e.reference().CustomerID]
+	if _, ok := customerEntries[e.ID]; !ok {
+		return fmt.Errorf("entry %s: %w", e.ID, ErrNotFound)
+	}
+	delete(customerEntries, e.ID)
+	return nil
+}
+
+// LoadEntries implements Backend.
+func (b *MemoryBackend) LoadEntries(customerID uuid.UUID, from, to time.Time) ([]*Entry, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var entries []*Entry
+	for _, e := range b.entries[customerID] {
+		e := e
+		if inRange(e.StartTS, from, to) {
+			entries = append(entries, &e)
+		}
+	}
+	sort.Sort(Entries(entries))
+	return entries, nil
+}
+
+// WalkEntries implements Backend, the entries are already in memory so it walks a sorted copy of them.
+func (b *MemoryBackend) WalkEntries(ctx context.Context, customerID uuid.UUID, from, to time.Time, fn func(e *Entry) error) error {
+	entries, err := b.LoadEntries(customerID, from, to)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return walkResult(err)
+		}
+	}
+	return nil
+}
+
+// LastEntry implements Backend.
+func (b *MemoryBackend) LastEntry(customerID uuid.UUID) (*Entry, error) {
+	entries, err := b.LoadEntries(customerID, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entries for customer %s: %w", customerID, ErrNotFound)
+	}
+	return entries[len(entries)-1], nil
+}
+
+// Close implements Backend.
+func (b *MemoryBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,407 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MergeResult is the outcome of merging a data file changed on two machines.
+type MergeResult struct {
+	Content   []byte
+	Resolved  []string // changes made both ways that could be combined
+	Conflicts []string // changes made both ways that need a decision, the local one was kept
+}
+
+// isEntryFile tells if a slash separated path relative to the data root is an entry file.
+func isEntryFile(name string) bool {
+	return strings.HasPrefix(name, "tasks/") && path.Ext(name) == ".json"
+}
+
+// isLedgerFile tells if a slash separated path relative to the data root is a customer ledger.
+func isLedgerFile(name string) bool {
+	return strings.HasPrefix(name, "ledger/") && path.Ext(name) == ".jsonl"
+}
+
+// MergeDataFile merges the versions of a data file changed on both sides since base, base is
+// nil when both sides added the file. name is the slash separated path of the file relative to
+// the data root, it tells how to merge it: entries field by field, tasks, customers and running
+// timers by ID and ledgers by chaining the records of both sides.
+func MergeDataFile(name string, base, ours, theirs []byte) (*MergeResult, error) {
+	r := &MergeResult{}
+	var err error
+	switch {
+	case isEntryFile(name):
+		r.Content, err = mergeEntry(base, ours, theirs, r)
+	case strings.HasPrefix(name, "customers/") && path.Base(name) == "tasks.json":
+		r.Content, err = mergeTasks(base, ours, theirs, r)
+	case strings.HasPrefix(name, "customers/") && path.Base(name) == "metadata.json":
+		r.Content, err = mergeCustomer(base, ours, theirs, r)
+	case isLedgerFile(name):
+		r.Content, err = mergeLedger(ours, theirs, r)
+	case name == "active.json":
+		r.Content, err = mergeActive(base, ours, theirs, r)
+	default:
+		r.Content = ours
+		r.Conflicts = append(r.Conflicts, "changed both ways, kept the local version")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("merging %s: %w", name, err)
+	}
+	for i := range r.Resolved {
+		r.Resolved[i] = name + ": " + r.Resolved[i]
+	}
+	for i := range r.Conflicts {
+		r.Conflicts[i] = name + ": " + r.Conflicts[i]
+	}
+	return r, nil
+}
+
+// fieldResolver picks the value of a field changed both ways, a nil value means the field was
+// removed on that side. resolved tells if both changes could be combined.
+type fieldResolver func(key string, ours, theirs json.RawMessage) (value json.RawMessage, resolution string, resolved bool, err error)
+
+// keepOurs is the fieldResolver of fields whose changes cannot be combined.
+func keepOurs(key string, ours, theirs json.RawMessage) (json.RawMessage, string, bool, error) {
+	return ours, fmt.Sprintf("%s here and %s there, kept the local one", orNone(ours), orNone(theirs)), false, nil
+}
+
+// orNone returns a JSON value as text for merge reports.
+func orNone(value json.RawMessage) string {
+	if value == nil {
+		return "none"
+	}
+	return string(value)
+}
+
+// mergeFields merges three versions of a JSON object field by field, the fields changed both
+// ways are resolved by resolve and reported in r prefixed by what.
+func mergeFields(what string, base, ours, theirs map[string]json.RawMessage, resolve fieldResolver, r *MergeResult) (map[string]json.RawMessage, error) {
+	keys := map[string]bool{}
+	for _, fields := range []map[string]json.RawMessage{base, ours, theirs} {
+		for key := range fields {
+			keys[key] = true
+		}
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	merged := map[string]json.RawMessage{}
+	for _, key := range sortedKeys {
+		b, o, t := base[key], ours[key], theirs[key]
+		var value json.RawMessage
+		switch {
+		case jsonEqual(o, t), jsonEqual(b, t):
+			value = o
+		case jsonEqual(b, o):
+			value = t
+		default:
+			var resolution string
+			var resolved bool
+			var err error
+			value, resolution, resolved, err = resolve(key, o, t)
+			if err != nil {
+				return nil, err
+			}
+			report := fmt.Sprintf("%s%s changed both ways, %s", what, key, resolution)
+			if resolved {
+				r.Resolved = append(r.Resolved, report)
+			} else {
+				r.Conflicts = append(r.Conflicts, report)
+			}
+		}
+		if value != nil {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// decodeObject decodes a JSON object, nil data is an empty one.
+func decodeObject(data []byte) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if data == nil {
+		return fields, nil
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// mergeEntry merges three versions of an entry file field by field, fields changed both ways
+// are resolved by resolveEntryField.
+func mergeEntry(base, ours, theirs []byte, r *MergeResult) ([]byte, error) {
+	var versions [3]map[string]json.RawMessage
+	for i, data := range [][]byte{base, ours, theirs} {
+		var err error
+		if versions[i], err = decodeObject(data); err != nil {
+			return nil, fmt.Errorf("decoding entry: %w", err)
+		}
+	}
+	merged, err := mergeFields("", versions[0], versions[1], versions[2], resolveEntryField, r)
+	if err != nil {
+		return nil, err
+	}
+	// decode into an Entry so the result is written the same way Entry.Save writes it
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("decoding merged entry: %w", err)
+	}
+	data, err = json.Marshal(&e)
+	if err != nil {
+		return nil, fmt.Errorf("encoding merged entry: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// resolveEntryField is the fieldResolver of entries, it knows how to combine ends, comments and tags.
+func resolveEntryField(key string, ours, theirs json.RawMessage) (json.RawMessage, string, bool, error) {
+	switch key {
+	case "end_ts":
+		// finishing wins over still running and the longest run wins over the shorter
+		if ours == nil {
+			return theirs, "kept the finished one", true, nil
+		}
+		if theirs == nil {
+			return ours, "kept the finished one", true, nil
+		}
+		var o, t time.Time
+		if err := json.Unmarshal(ours, &o); err != nil {
+			return nil, "", false, fmt.Errorf("decoding end_ts: %w", err)
+		}
+		if err := json.Unmarshal(theirs, &t); err != nil {
+			return nil, "", false, fmt.Errorf("decoding end_ts: %w", err)
+		}
+		if t.After(o) {
+			return theirs, "kept the later one", true, nil
+		}
+		return ours, "kept the later one", true, nil
+	case "comment":
+		var o, t string
+		if err := json.Unmarshal(ours, &o); ours != nil && err != nil {
+			return nil, "", false, fmt.Errorf("decoding comment: %w", err)
+		}
+		if err := json.Unmarshal(theirs, &t); theirs != nil && err != nil {
+			return nil, "", false, fmt.Errorf("decoding comment: %w", err)
+		}
+		switch {
+		case o == "":
+			return theirs, "kept the non empty one", true, nil
+		case t == "":
+			return ours, "kept the non empty one", true, nil
+		}
+		joined, err := json.Marshal(o + "\n" + t)
+		return joined, "joined both", true, err
+	case "tags":
+		var o, t []string
+		if err := json.Unmarshal(ours, &o); ours != nil && err != nil {
+			return nil, "", false, fmt.Errorf("decoding tags: %w", err)
+		}
+		if err := json.Unmarshal(theirs, &t); theirs != nil && err != nil {
+			return nil, "", false, fmt.Errorf("decoding tags: %w", err)
+		}
+		tags := append([]string(nil), o...)
+		for _, tag := range t {
+			if !containsFold(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		union, err := json.Marshal(tags)
+		return union, "kept the tags of both", true, err
+	case "breaks":
+		var o, t []Break
+		if err := json.Unmarshal(ours, &o); ours != nil && err != nil {
+			return nil, "", false, fmt.Errorf("decoding breaks: %w", err)
+		}
+		if err := json.Unmarshal(theirs, &t); theirs != nil && err != nil {
+			return nil, "", false, fmt.Errorf("decoding breaks: %w", err)
+		}
+		union, err := json.Marshal(mergeBreaks(o, t))
+		return union, "kept the breaks of both", true, err
+	default:
+		return keepOurs(key, ours, theirs)
+	}
+}
+
+// mergeBreaks returns the breaks of both sides sorted by start, a break taken on both sides
+// keeps the side that ended it, the later end if both did.
+func mergeBreaks(ours, theirs []Break) []Break {
+	byStart := map[time.Time]Break{}
+	for _, b := range append(ours, theirs...) {
+		key := b.Start.UTC()
+		kept, ok := byStart[key]
+		if !ok || kept.End == nil || (b.End != nil && b.End.After(*kept.End)) {
+			byStart[key] = b
+		}
+	}
+	breaks := make([]Break, 0, len(byStart))
+	for _, b := range byStart {
+		breaks = append(breaks, b)
+	}
+	sort.Slice(breaks, func(i, j int) bool {
+		return breaks[i].Start.Before(breaks[j].Start)
+	})
+	return breaks
+}
+
+// mergeTasks merges three versions of a tasks file task by task, matching them by ID. Tasks
+// keep the local order, the ones only added there go last.
+func mergeTasks(base, ours, theirs []byte, r *MergeResult) ([]byte, error) {
+	var versions [3][]map[string]json.RawMessage
+	var byID [3]map[string]map[string]json.RawMessage
+	for i, data := range [][]byte{base, ours, theirs} {
+		if data != nil {
+			if err := json.Unmarshal(data, &versions[i]); err != nil {
+				return nil, fmt.Errorf("decoding tasks: %w", err)
+			}
+		}
+		byID[i] = map[string]map[string]json.RawMessage{}
+		for _, task := range versions[i] {
+			var id string
+			if err := json.Unmarshal(task["id"], &id); err != nil {
+				return nil, fmt.Errorf("decoding task id: %w", err)
+			}
+			byID[i][id] = task
+		}
+	}
+	var merged []map[string]json.RawMessage
+	seen := map[string]bool{}
+	for _, side := range versions[1:] {
+		for _, task := range side {
+			var id string
+			json.Unmarshal(task["id"], &id)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			b, o, t := byID[0][id], byID[1][id], byID[2][id]
+			what := fmt.Sprintf("task %s (%s) ", id, taskName(o, t, b))
+			switch {
+			case o != nil && t != nil:
+				task, err := mergeFields(what, b, o, t, keepOurs, r)
+				if err != nil {
+					return nil, err
+				}
+				merged = append(merged, task)
+			case b == nil:
+				// added on one side
+				merged = append(merged, task)
+			case o == nil && objectEqual(b, t), t == nil && objectEqual(b, o):
+				// removed on the other side
+			default:
+				r.Conflicts = append(r.Conflicts, what+"removed on one side and edited on the other, kept the edit")
+				merged = append(merged, task)
+			}
+		}
+	}
+
+	tasks := make([]*Task, 0, len(merged))
+	for _, fields := range merged {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		t := &Task{}
+		if err := json.Unmarshal(data, t); err != nil {
+			return nil, fmt.Errorf("decoding merged task: %w", err)
+		}
+		tasks = append(tasks, t)
+	}
+	// written the same way CustomerTasks.Save writes them
+	var buf bytes.Buffer
+	m := json.NewEncoder(&buf)
+	m.SetIndent("", "  ")
+	if err := m.Encode(tasks); err != nil {
+		return nil, fmt.Errorf("encoding merged tasks: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// taskName returns the name of the first of the given task versions that has one, for reports.
+func taskName(versions ...map[string]json.RawMessage) string {
+	for _, v := range versions {
+		var name string
+		if v != nil && json.Unmarshal(v["name"], &name) == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// mergeCustomer merges three versions of a customer metadata file field by field.
+func mergeCustomer(base, ours, theirs []byte, r *MergeResult) ([]byte, error) {
+	var versions [3]map[string]json.RawMessage
+	for i, data := range [][]byte{base, ours, theirs} {
+		var err error
+		if versions[i], err = decodeObject(data); err != nil {
+			return nil, fmt.Errorf("decoding customer: %w", err)
+		}
+	}
+	merged, err := mergeFields("customer ", versions[0], versions[1], versions[2], keepOurs, r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var c Customer
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("decoding merged customer: %w", err)
+	}
+	// written the same way Customer.Save writes it
+	var buf bytes.Buffer
+	m := json.NewEncoder(&buf)
+	m.SetIndent("", "  ")
+	if err := m.Encode(&c); err != nil {
+		return nil, fmt.Errorf("encoding merged customer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// objectEqual tells if two decoded JSON objects hold the same fields.
+func objectEqual(a, b map[string]json.RawMessage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if !jsonEqual(value, b[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonEqual tells if two JSON values are the same once compacted, nil stands for a missing value.
+func jsonEqual(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// containsFold tells if values holds s ignoring case.
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeDataFile_Entry(t *testing.T) {
+	const base = `"comment":"","start_ts":"2024-05-02T09:00:00Z"`
+	tests := []struct {
+		name          string
+		base          string
+		ours          string
+		theirs        string
+		want          map[string]any
+		wantResolved  int
+		wantConflicts int
+	}{
+		{
+			name:   "different fields",
+			base:   base,
+			ours:   `"comment":"design","start_ts":"2024-05-02T09:00:00Z"`,
+			theirs: `"comment":"","start_ts":"2024-05-02T09:00:00Z","end_ts":"2024-05-02T10:00:00Z"`,
+			want:   map[string]any{"comment": "design", "end_ts": "2024-05-02T10:00:00Z"},
+		},
+		{
+			name:         "finished wins over running",
+			base:         `"comment":"","start_ts":"2024-05-02T09:00:00Z","end_ts":"2024-05-02T09:30:00Z"`,
+			ours:         base,
+			theirs:       `"comment":"","start_ts":"2024-05-02T09:00:00Z","end_ts":"2024-05-02T11:00:00Z"`,
+			want:         map[string]any{"end_ts": "2024-05-02T11:00:00Z"},
+			wantResolved: 1,
+		},
+		{
+			name:         "later end wins",
+			base:         base,
+			ours:         `"comment":"","start_ts":"2024-05-02T09:00:00Z","end_ts":"2024-05-02T12:00:00Z"`,
+			theirs:       `"comment":"","start_ts":"2024-05-02T09:00:00Z","end_ts":"2024-05-02T11:00:00Z"`,
+			want:         map[string]any{"end_ts": "2024-05-02T12:00:00Z"},
+			wantResolved: 1,
+		},
+		{
+			name:         "comments are joined",
+			base:         base,
+			ours:         `"comment":"design","start_ts":"2024-05-02T09:00:00Z"`,
+			theirs:       `"comment":"review","start_ts":"2024-05-02T09:00:00Z"`,
+			want:         map[string]any{"comment": "design\nreview"},
+			wantResolved: 1,
+		},
+		{
+			name:         "tags are united",
+			base:         base,
+			ours:         `"comment":"","start_ts":"2024-05-02T09:00:00Z","tags":["billable"]`,
+			theirs:       `"comment":"","start_ts":"2024-05-02T09:00:00Z","tags":["Billable","meeting"]`,
+			want:         map[string]any{"tags": []any{"billable", "meeting"}},
+			wantResolved: 1,
+		},
+		{
+			name:   "breaks are united",
+			base:   `"comment":"","start_ts":"2024-05-02T09:00:00Z","breaks":[{"start":"2024-05-02T10:00:00Z"}]`,
+			ours:   `"comment":"","start_ts":"2024-05-02T09:00:00Z","breaks":[{"start":"2024-05-02T10:00:00Z","end":"2024-05-02T10:15:00Z"}]`,
+			theirs: `"comment":"","start_ts":"2024-05-02T09:00:00Z","breaks":[{"start":"2024-05-02T10:00:00Z","end":"2024-05-02T10:05:00Z"},{"start":"2024-05-02T10:40:00Z"}]`,
+			want: map[string]any{"breaks": []any{
+				map[string]any{"start": "2024-05-02T10:00:00Z", "end": "2024-05-02T10:15:00Z"},
+				map[string]any{"start": "2024-05-02T10:40:00Z"},
+			}},
+			wantResolved: 1,
+		},
+		{
+			name:          "added on both sides",
+			ours:          `"comment":"design","start_ts":"2024-05-02T09:00:00Z"`,
+			theirs:        `"comment":"design","start_ts":"2024-05-02T09:30:00Z"`,
+			want:          map[string]any{"comment": "design", "start_ts": "2024-05-02T09:00:00Z"},
+			wantConflicts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var base []byte
+			if tt.base != "" {
+				base = entryJSON(tt.base)
+			}
+			got, err := MergeDataFile(entryFile, base, entryJSON(tt.ours), entryJSON(tt.theirs))
+			if err != nil {
+				t.Fatalf("MergeDataFile() error = %v", err)
+			}
+			if len(got.Resolved) != tt.wantResolved || len(got.Conflicts) != tt.wantConflicts {
+				t.Errorf("MergeDataFile() resolved %q and conflicts %q, want %d and %d", got.Resolved, got.Conflicts, tt.wantResolved, tt.wantConflicts)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(got.Content, &fields); err != nil {
+				t.Fatalf("MergeDataFile() = %s, not JSON: %v", got.Content, err)
+			}
+			for key, want := range tt.want {
+				gotJSON, _ := json.Marshal(fields[key])
+				wantJSON, _ := json.Marshal(want)
+				if string(gotJSON) != string(wantJSON) {
+					t.Errorf("MergeDataFile() %s = %s, want %s", key, gotJSON, wantJSON)
+				}
+			}
+		})
+	}
+}
+
+// entryFile is where the entry of entryJSON is stored relative to the data root.
+const entryFile = "tasks/123e4567-e89b-12d3-a456-426614174000/2024/05/02/123e4567-e89b-12d3-a456-426614174200.json"
+
+// entryJSON returns an entry file holding the given fields of a fixed entry.
+func entryJSON(fields string) []byte {
+	return []byte(`{"task":"123e4567-e89b-12d3-a456-426614174000/123e4567-e89b-12d3-a456-426614174100",` +
+		`"id":"123e4567-e89b-12d3-a456-426614174200",` + fields + `}`)
+}
+
+func TestMergeDataFile_Tasks(t *testing.T) {
+	const tasksFile = "customers/123e4567-e89b-12d3-a456-426614174000/tasks.json"
+	task := func(n int, name string) string {
+		return fmt.Sprintf(`{"customer":"123e4567-e89b-12d3-a456-426614174000","id":"123e4567-e89b-12d3-a456-42661417410%d","external_id":"","name":%q}`, n, name)
+	}
+	tasks := func(tasks ...string) []byte {
+		return []byte("[" + strings.Join(tasks, ",") + "]")
+	}
+	base := tasks(task(1, "Design"), task(2, "Review"))
+	tests := []struct {
+		name          string
+		base          []byte
+		ours          []byte
+		theirs        []byte
+		want          []string
+		wantConflicts int
+	}{
+		{
+			name:   "renamed here and added there",
+			base:   base,
+			ours:   tasks(task(1, "Wireframes"), task(2, "Review")),
+			theirs: tasks(task(1, "Design"), task(2, "Review"), task(3, "Deploy")),
+			want:   []string{"Wireframes", "Review", "Deploy"},
+		},
+		{
+			name:          "renamed two ways",
+			base:          base,
+			ours:          tasks(task(1, "Wireframes"), task(2, "Review")),
+			theirs:        tasks(task(1, "Mockups"), task(2, "Review")),
+			want:          []string{"Wireframes", "Review"},
+			wantConflicts: 1,
+		},
+		{
+			name:   "removed here",
+			base:   base,
+			ours:   tasks(task(1, "Design")),
+			theirs: base,
+			want:   []string{"Design"},
+		},
+		{
+			name:          "removed here and edited there",
+			base:          base,
+			ours:          tasks(task(1, "Design")),
+			theirs:        tasks(task(1, "Design"), task(2, "Code review")),
+			want:          []string{"Design", "Code review"},
+			wantConflicts: 1,
+		},
+		{
+			name:   "added on both sides",
+			ours:   tasks(task(1, "Design")),
+			theirs: tasks(task(2, "Review")),
+			want:   []string{"Design", "Review"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeDataFile(tasksFile, tt.base, tt.ours, tt.theirs)
+			if err != nil {
+				t.Fatalf("MergeDataFile() error = %v", err)
+			}
+			if len(got.Conflicts) != tt.wantConflicts {
+				t.Errorf("MergeDataFile() conflicts = %q, want %d", got.Conflicts, tt.wantConflicts)
+			}
+			var merged []*Task
+			if err := json.Unmarshal(got.Content, &merged); err != nil {
+				t.Fatalf("MergeDataFile() = %s, not tasks: %v", got.Content, err)
+			}
+			var names []string
+			for _, task := range merged {
+				names = append(names, task.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("MergeDataFile() tasks = %q, want %q", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeDataFile_Customer(t *testing.T) {
+	const metadataFile = "customers/123e4567-e89b-12d3-a456-426614174000/metadata.json"
+	customer := func(name string) []byte {
+		return []byte(fmt.Sprintf(`{"id":"123e4567-e89b-12d3-a456-426614174000","name":%q}`, name))
+	}
+	got, err := MergeDataFile(metadataFile, customer("ACME"), customer("ACME Corp"), customer("ACME Inc"))
+	if err != nil {
+		t.Fatalf("MergeDataFile() error = %v", err)
+	}
+	var c Customer
+	if err := json.Unmarshal(got.Content, &c); err != nil {
+		t.Fatalf("MergeDataFile() = %s, not a customer: %v", got.Content, err)
+	}
+	if c.Name != "ACME Corp" || len(got.Conflicts) != 1 {
+		t.Errorf("MergeDataFile() = %q with conflicts %q, want the local name and a conflict", c.Name, got.Conflicts)
+	}
+}
+
+func TestMergeDataFile_Ledger(t *testing.T) {
+	const ledgerFile = "ledger/123e4567-e89b-12d3-a456-426614174000.jsonl"
+	encode := func(records []ledgerRecord) []byte {
+		var buf bytes.Buffer
+		if err := encodeLedger(&buf, records); err != nil {
+			t.Fatalf("encodeLedger() error = %v", err)
+		}
+		return buf.Bytes()
+	}
+	extend := func(records []ledgerRecord, entryHash string) []ledgerRecord {
+		l := &ledger{records: append([]ledgerRecord(nil), records...)}
+		r := ledgerRecord{Op: ledgerSave, EntryID: uuid.New(), EntryHash: entryHash}
+		l.link(&r, nil)
+		return append(l.records, r)
+	}
+	base := extend(nil, "shared")
+	ours := extend(base, "here")
+	theirs := extend(base, "there")
+
+	got, err := MergeDataFile(ledgerFile, encode(base), encode(ours), encode(theirs))
+	if err != nil {
+		t.Fatalf("MergeDataFile() error = %v", err)
+	}
+	merged, err := decodeLedger(bytes.NewReader(got.Content))
+	if err != nil {
+		t.Fatalf("MergeDataFile() = %s, not a ledger: %v", got.Content, err)
+	}
+	var ops, hashes []string
+	prev := ""
+	for i, r := range merged {
+		if r.Seq != uint64(i+1) || r.Prev != prev || r.computeHash() != r.Hash {
+			t.Errorf("MergeDataFile() record %d = %+v, does not follow the chain", i+1, r)
+		}
+		prev = r.Hash
+		ops = append(ops, r.Op)
+		hashes = append(hashes, r.EntryHash)
+	}
+	if want := []string{ledgerSave, ledgerSave, ledgerSave, ledgerMerge}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("MergeDataFile() ops = %q, want %q", ops, want)
+	}
+	if want := []string{"shared", "here", "there", theirs[1].Hash}; !reflect.DeepEqual(hashes, want) {
+		t.Errorf("MergeDataFile() entry hashes = %q, want %q", hashes, want)
+	}
+
+	// a side that did not change anything is left out
+	got, err = MergeDataFile(ledgerFile, encode(base), encode(base), encode(theirs))
+	if err != nil {
+		t.Fatalf("MergeDataFile() error = %v", err)
+	}
+	if !bytes.Equal(got.Content, encode(theirs)) {
+		t.Errorf("MergeDataFile() = %s, want theirs", got.Content)
+	}
+}
+
+func TestMergeDataFile_Active(t *testing.T) {
+	start := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	timers := make([]activeEntry, 4)
+	for i := range timers {
+		timers[i] = activeEntry{EntryID: uuid.New(), CustomerID: uuid.New(), Start: start.Add(time.Duration(i) * time.Hour)}
+	}
+	encode := func(active ...activeEntry) []byte {
+		var buf bytes.Buffer
+		if err := encodeActive(&buf, active); err != nil {
+			t.Fatalf("encodeActive() error = %v", err)
+		}
+		return buf.Bytes()
+	}
+	tests := []struct {
+		name   string
+		base   []byte
+		ours   []byte
+		theirs []byte
+		want   []activeEntry
+	}{
+		{
+			name:   "started on both sides",
+			ours:   encode(timers[0]),
+			theirs: encode(timers[1]),
+			want:   timers[:2],
+		},
+		{
+			name:   "finished there and started here",
+			base:   encode(timers[0]),
+			ours:   encode(timers[0], timers[1]),
+			theirs: encode(),
+			want:   timers[1:2],
+		},
+		{
+			name:   "finished here and started there",
+			base:   encode(timers[0], timers[1]),
+			ours:   encode(timers[1]),
+			theirs: encode(timers[0], timers[1], timers[3]),
+			want:   []activeEntry{timers[1], timers[3]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeDataFile("active.json", tt.base, tt.ours, tt.theirs)
+			if err != nil {
+				t.Fatalf("MergeDataFile() error = %v", err)
+			}
+			if len(got.Conflicts) != 0 {
+				t.Errorf("MergeDataFile() conflicts = %q, want none", got.Conflicts)
+			}
+			if want := encode(tt.want...); !bytes.Equal(got.Content, want) {
+				t.Errorf("MergeDataFile() = %s, want %s", got.Content, want)
+			}
+		})
+	}
+}
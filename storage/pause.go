@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotRunning is returned when pausing or resuming an entry already finished.
+var ErrNotRunning = errors.New("entry is not running")
+
+// ErrPaused is returned when pausing an entry already paused.
+var ErrPaused = errors.New("entry is paused")
+
+// Break is a pause taken while an entry was running, it does not count towards its net duration.
+type Break struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"` // nil while the entry is paused
+}
+
+// Paused tells if the entry is running but paused.
+func (e *Entry) Paused() bool {
+	return e.EndTs == nil && len(e.Breaks) > 0 && e.Breaks[len(e.Breaks)-1].End == nil
+}
+
+// end returns when the entry ended or now if it is still running.
+func (e *Entry) end(now time.Time) time.Time {
+	if e.EndTs != nil {
+		return *e.EndTs
+	}
+	return now
+}
+
+// Duration returns the gross time of the entry, breaks included, up to now if it is still running.
+func (e *Entry) Duration(now time.Time) time.Duration {
+	return e.end(now).Sub(e.StartTS)
+}
+
+// BreakDuration returns the time spent in the breaks of the entry, up to now if it is paused.
+func (e *Entry) BreakDuration(now time.Time) time.Duration {
+	end := e.end(now)
+	var total time.Duration
+	for _, b := range e.Breaks {
+		if start, stop, ok := clipBreak(b, e.StartTS, end); ok {
+			total += stop.Sub(start)
+		}
+	}
+	return total
+}
+
+// NetDuration returns the time worked in the entry, its duration without the breaks.
+func (e *Entry) NetDuration(now time.Time) time.Duration {
+	return e.Duration(now) - e.BreakDuration(now)
+}
+
+// clipBreak returns the part of a break within [from, to), an open break lasts until to.
+func clipBreak(b Break, from, to time.Time) (time.Time, time.Time, bool) {
+	start, stop := b.Start, to
+	if b.End != nil && b.End.Before(stop) {
+		stop = *b.End
+	}
+	if start.Before(from) {
+		start = from
+	}
+	return start, stop, stop.After(start)
+}
+
+// clipBreaks returns the breaks within [from, to), a break still open at to is closed there.
+func clipBreaks(breaks []Break, from, to time.Time) []Break {
+	var clipped []Break
+	for _, b := range breaks {
+		if start, stop, ok := clipBreak(b, from, to); ok {
+			clipped = append(clipped, Break{Start: start, End: &stop})
+		}
+	}
+	return clipped
+}
+
+// PauseEntry pauses a running entry at the given time, until ResumeEntry.
+func (s *Store) PauseEntry(e *Entry, at time.Time) error {
+	switch {
+	case e.EndTs != nil:
+		return fmt.Errorf("pausing entry %s: %w", e.ID, ErrNotRunning)
+	case e.Paused():
+		return fmt.Errorf("pausing entry %s: %w", e.ID, ErrPaused)
+	case at.Before(e.StartTS):
+		return fmt.Errorf("entry %s cannot be paused at %s, before its start at %s", e.ID, at, e.StartTS)
+	}
+	if n := len(e.Breaks); n > 0 && at.Before(*e.Breaks[n-1].End) {
+		return fmt.Errorf("entry %s cannot be paused at %s, before its last break ended at %s", e.ID, at, *e.Breaks[n-1].End)
+	}
+	e.Breaks = append(e.Breaks, Break{Start: at})
+	if err := s.saveEntry(e, "pause"); err != nil {
+		e.Breaks = e.Breaks[:len(e.Breaks)-1]
+		return err
+	}
+	return nil
+}
+
+// ResumeEntry resumes a paused entry at the given time.
+func (s *Store) ResumeEntry(e *Entry, at time.Time) error {
+	switch {
+	case e.EndTs != nil:
+		return fmt.Errorf("resuming entry %s: %w", e.ID, ErrNotRunning)
+	case !e.Paused():
+		return fmt.Errorf("entry %s is not paused", e.ID)
+	}
+	b := &e.Breaks[len(e.Breaks)-1]
+	if at.Before(b.Start) {
+		return fmt.Errorf("entry %s cannot be resumed at %s, before it was paused at %s", e.ID, at, b.Start)
+	}
+	b.End = &at
+	if err := s.saveEntry(e, "resume"); err != nil {
+		b.End = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEntry_NetDuration(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	ptr := func(t time.Time) *time.Time { return &t }
+	tests := []struct {
+		name      string
+		end       *time.Time
+		breaks    []Break
+		now       time.Time
+		wantGross time.Duration
+		wantNet   time.Duration
+	}{
+		{
+			name:      "no breaks",
+			end:       ptr(at(60)),
+			wantGross: time.Hour,
+			wantNet:   time.Hour,
+		},
+		{
+			name:      "finished with breaks",
+			end:       ptr(at(120)),
+			breaks:    []Break{{Start: at(30), End: ptr(at(45))}, {Start: at(90), End: ptr(at(100))}},
+			wantGross: 2 * time.Hour,
+			wantNet:   95 * time.Minute,
+		},
+		{
+			name:      "paused",
+			breaks:    []Break{{Start: at(30)}},
+			now:       at(60),
+			wantGross: time.Hour,
+			wantNet:   30 * time.Minute,
+		},
+		{
+			name:      "break past the end",
+			end:       ptr(at(60)),
+			breaks:    []Break{{Start: at(50), End: ptr(at(70))}},
+			wantGross: time.Hour,
+			wantNet:   50 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Entry{StartTS: start, EndTs: tt.end, Breaks: tt.breaks}
+			if got := e.Duration(tt.now); got != tt.wantGross {
+				t.Errorf("Entry.Duration() = %v, want %v", got, tt.wantGross)
+			}
+			if got := e.NetDuration(tt.now); got != tt.wantNet {
+				t.Errorf("Entry.NetDuration() = %v, want %v", got, tt.wantNet)
+			}
+		})
+	}
+}
+
+func TestStore_PauseEntry(t *testing.T) {
+	s, tasks := newTimerStore(t, Options{})
+	start := time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC)
+	e := NewEntry(tasks[0], start)
+	if _, err := s.Start(e); err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	if err := s.ResumeEntry(e, start.Add(time.Minute)); err == nil {
+		t.Error("Store.ResumeEntry() of a running entry succeeded")
+	}
+	if err := s.PauseEntry(e, start.Add(30*time.Minute)); err != nil {
+		t.Fatalf("Store.PauseEntry() error = %v", err)
+	}
+	if err := s.PauseEntry(e, start.Add(40*time.Minute)); !errors.Is(err, ErrPaused) {
+		t.Errorf("Store.PauseEntry() twice error = %v, want %v", err, ErrPaused)
+	}
+	if err := s.ResumeEntry(e, start.Add(45*time.Minute)); err != nil {
+		t.Fatalf("Store.ResumeEntry() error = %v", err)
+	}
+	// paused when finished the next day, the break goes on until then and is split with the entry
+	if err := s.PauseEntry(e, start.Add(90*time.Minute)); err != nil {
+		t.Fatalf("Store.PauseEntry() error = %v", err)
+	}
+	if err := s.FinishEntryAt(e, start.Add(3*time.Hour)); err != nil {
+		t.Fatalf("Store.FinishEntryAt() error = %v", err)
+	}
+	if err := s.PauseEntry(e, start.Add(4*time.Hour)); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Store.PauseEntry() after finishing error = %v, want %v", err, ErrNotRunning)
+	}
+
+	sessions, err := s.LoadSessions(EntryFilter{CustomerID: tasks[0].Customer.ID})
+	if err != nil {
+		t.Fatalf("Store.LoadSessions() error = %v", err)
+	}
+	if len(sessions) != 1 || len(sessions[0].Fragments) != 2 {
+		t.Fatalf("Store.LoadSessions() = %v, want a session of 2 fragments", sessions)
+	}
+	if got := sessions[0].Duration(start.Add(5 * time.Hour)); got != 3*time.Hour {
+		t.Errorf("Session.Duration() = %v, want %v", got, 3*time.Hour)
+	}
+	if got := sessions[0].NetDuration(start.Add(5 * time.Hour)); got != 75*time.Minute {
+		t.Errorf("Session.NetDuration() = %v, want %v", got, 75*time.Minute)
+	}
+	for i, want := range []int{2, 1} {
+		if got := len(sessions[0].Fragments[i].Breaks); got != want {
+			t.Errorf("fragment %d has %d breaks, want %d", i, got, want)
+		}
+	}
+}
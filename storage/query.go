@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// EntryFilter selects the entries returned by Store.LoadEntries, zero fields do not filter.
+type EntryFilter struct {
+	From       time.Time // entries started at or after From
+	To         time.Time // entries started before To
+	CustomerID uuid.UUID
+	TaskID     uuid.UUID
+	Tags       []string // entries carrying all of these tags
+	Text       string   // case insensitive substring of the comment
+}
+
+// matches tells if the entry passes the filters that are not handled by the backend range query.
+func (f *EntryFilter) matches(e *Entry) bool {
+	if f.TaskID != uuid.Nil && e.reference().TaskID != f.TaskID {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !hasTag(e, tag) {
+			return false
+		}
+	}
+	if f.Text != "" && !strings.Contains(strings.ToLower(e.Comment), strings.ToLower(f.Text)) {
+		return false
+	}
+	return true
+}
+
+// hasTag tells if the entry carries the tag, ignoring case.
+func hasTag(e *Entry, tag string) bool {
+	return containsFold(e.Tags, tag)
+}
+
+// LoadEntries returns the entries matching the filter sorted by start date, for a single customer
+// when the filter names one or across all of them otherwise.
+func (s *Store) LoadEntries(f EntryFilter) ([]*Entry, error) {
+	// a single lock over the whole walk gives a consistent snapshot
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var entries []*Entry
+	err = s.WalkEntries(context.Background(), f, func(e *Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WalkEntries calls fn for the entries matching the filter in chronological order, merging the
+// customers as it goes, without ever loading more than a few of them at once. The walk stops at
+// the first error returned by fn or when ctx is done, fn returning ErrStopWalk is not an error.
+func (s *Store) WalkEntries(ctx context.Context, f EntryFilter, fn func(e *Entry) error) error {
+	yield := func(e *Entry) error {
+		if !f.matches(e) {
+			return nil
+		}
+		if err := s.resolveEntry(e); err != nil {
+			return fmt.Errorf("resolving entry %s: %w", e.ID, err)
+		}
+		return fn(e)
+	}
+	if f.CustomerID != uuid.Nil {
+		return s.backend.WalkEntries(ctx, f.CustomerID, f.From, f.To, yield)
+	}
+
+	// the streams are stopped by cancelling their context when the walk ends early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var streams []*entryStream
+	for _, customerID := range s.customerIDs() {
+		st := streamEntries(ctx, s.backend, customerID, f.From, f.To)
+		if st.next() {
+			streams = append(streams, st)
+		} else if err := st.wait(); err != nil {
+			return fmt.Errorf("walking entries of customer %s: %w", customerID, err)
+		}
+	}
+	for len(streams) > 0 {
+		first := 0
+		for i, st := range streams {
+			if st.head.StartTS.Before(streams[first].head.StartTS) {
+				first = i
+			}
+		}
+		st := streams[first]
+		if err := yield(st.head); err != nil {
+			return walkResult(err)
+		}
+		if !st.next() {
+			if err := st.wait(); err != nil {
+				return fmt.Errorf("walking entries of customer %s: %w", st.customerID, err)
+			}
+			streams = append(streams[:first], streams[first+1:]...)
+		}
+	}
+	return nil
+}
+
+// entryStream hands over the entries of a backend walk running in its own goroutine one at a time.
+type entryStream struct {
+	customerID uuid.UUID
+	entries    chan *Entry
+	err        chan error
+	head       *Entry // the entry next returned
+}
+
+// streamEntries starts walking the entries of a customer, the walk runs until it is done or ctx is.
+func streamEntries(ctx context.Context, b Backend, customerID uuid.UUID, from, to time.Time) *entryStream {
+	st := &entryStream{customerID: customerID, entries: make(chan *Entry), err: make(chan error, 1)}
+	go func() {
+		defer close(st.entries)
+		st.err <- b.WalkEntries(ctx, customerID, from, to, func(e *Entry) error {
+			select {
+			case st.entries <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return st
+}
+
+// next moves head to the following entry, it returns false once the walk is over.
+func (st *entryStream) next() bool {
+	e, ok := <-st.entries
+	st.head = e
+	return ok
+}
+
+// wait returns how the walk ended, it must only be called once next returned false.
+func (st *entryStream) wait() error {
+	return <-st.err
+}
+
+// customerIDs returns the IDs of all the cached customers.
+func (s *Store) customerIDs() []uuid.UUID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]uuid.UUID, 0, len(s.customers))
+	for id := range s.customers {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestStore_LoadEntries(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	var tasks []*Task
+	for _, name := range []string{"First Customer", "Second Customer"} {
+		customer := NewCustomer(name)
+		if err := s.AddCustomer(customer); err != nil {
+			t.Fatalf("Store.AddCustomer() error = %v", err)
+		}
+		ct, err := s.LoadTasks(customer.ID)
+		if err != nil {
+			t.Fatalf("Store.LoadTasks() error = %v", err)
+		}
+		for _, taskName := range []string{"Design", "Review"} {
+			task := &Task{ID: uuid.New(), Customer: customer, Name: taskName}
+			if err := ct.AddTask(task); err != nil {
+				t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+			}
+			tasks = append(tasks, task)
+		}
+		if err := s.SaveTasks(ct); err != nil {
+			t.Fatalf("Store.SaveTasks() error = %v", err)
+		}
+	}
+	day := time.Date(2023, time.December, 30, 9, 0, 0, 0, time.Local)
+	entry := func(task *Task, start time.Time, comment string, tags ...string) *Entry {
+		e := NewEntry(task, start)
+		e.Comment = comment
+		e.Tags = tags
+		if err := s.SaveEntry(e); err != nil {
+			t.Fatalf("Store.SaveEntry() error = %v", err)
+		}
+		return e
+	}
+	// saved out of order and across a year boundary
+	e3 := entry(tasks[2], day.AddDate(0, 0, 3), "Sprint planning", "meeting")
+	e1 := entry(tasks[0], day, "Wireframes", "billable")
+	e4 := entry(tasks[1], day.AddDate(0, 0, 3).Add(time.Hour), "Code review", "billable", "meeting")
+	e2 := entry(tasks[3], day.AddDate(0, 0, 1), "Review the wireframes")
+
+	tests := []struct {
+		name   string
+		filter EntryFilter
+		want   []*Entry
+	}{
+		{name: "everything", filter: EntryFilter{}, want: []*Entry{e1, e2, e3, e4}},
+		{name: "range", filter: EntryFilter{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 3).Add(time.Hour)}, want: []*Entry{e2, e3}},
+		{name: "customer", filter: EntryFilter{CustomerID: tasks[0].Customer.ID}, want: []*Entry{e1, e4}},
+		{name: "task", filter: EntryFilter{CustomerID: tasks[0].Customer.ID, TaskID: tasks[1].ID}, want: []*Entry{e4}},
+		{name: "task without customer", filter: EntryFilter{TaskID: tasks[2].ID}, want: []*Entry{e3}},
+		{name: "tags", filter: EntryFilter{Tags: []string{"Meeting", "billable"}}, want: []*Entry{e4}},
+		{name: "text", filter: EntryFilter{Text: "wireframes"}, want: []*Entry{e1, e2}},
+		{name: "no match", filter: EntryFilter{From: day.AddDate(1, 0, 0)}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.LoadEntries(tt.filter)
+			if err != nil {
+				t.Fatalf("Store.LoadEntries() error = %v", err)
+			}
+			assertEntries(t, got, tt.want)
+			for _, e := range got {
+				if e.Task == nil || e.Task.Customer == nil {
+					t.Errorf("Store.LoadEntries() entry %s has no resolved task", e.ID)
+				}
+			}
+		})
+	}
+
+	// walking across customers stops as soon as asked to
+	var walked []*Entry
+	err = s.WalkEntries(context.Background(), EntryFilter{}, func(e *Entry) error {
+		walked = append(walked, e)
+		if len(walked) == 3 {
+			return ErrStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.WalkEntries() error = %v", err)
+	}
+	assertEntries(t, walked, []*Entry{e1, e2, e3})
+}
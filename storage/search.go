@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/google/uuid"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// defaultTaskSearchLimit is how many tasks SearchTasks returns unless told otherwise.
+const defaultTaskSearchLimit = 10
+
+// TaskSearchOptions narrows a task search, the zero value searches the tasks of every customer.
+type TaskSearchOptions struct {
+	CustomerID      uuid.UUID // only search the tasks of this customer
+	Limit           int       // most tasks returned, defaults to 10
+	IncludeArchived bool      // also search archived tasks and the tasks of archived customers
+}
+
+// TaskMatch is a task found by SearchTasks.
+type TaskMatch struct {
+	Task       *Task
+	Score      float64
+	Highlights []Highlight // the parts of the task name and external ID that matched
+}
+
+// Highlight marks the bytes [Start, End) of a field matching a search.
+type Highlight struct {
+	Field string // "name" or "external_id" for tasks, "comment" for entries
+	Start int
+	End   int
+}
+
+// SearchTasks finds the tasks whose name or external ID match what was typed so far, best
+// matches first. Every word has to match a word of the name exactly, as a prefix or with a typo,
+// or the whole query has to match the start of the external ID, so it suits an autocomplete.
+// Archived tasks and customers are left out unless asked for.
+func (s *Store) SearchTasks(q string, opts TaskSearchOptions) ([]TaskMatch, error) {
+	words := strings.Fields(strings.ToLower(q))
+	if len(words) == 0 {
+		return nil, nil
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultTaskSearchLimit
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var indexes []bleve.Index
+	if opts.CustomerID != uuid.Nil {
+		index, ok := s.taskIndex[opts.CustomerID]
+		if !ok {
+			return nil, fmt.Errorf("customer %s: %w", opts.CustomerID, ErrNotFound)
+		}
+		indexes = append(indexes, index)
+	} else {
+		for id, index := range s.taskIndex {
+			if s.customers[id].Archived && !opts.IncludeArchived {
+				continue
+			}
+			indexes = append(indexes, index)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	tq := taskQuery(words)
+	if !opts.IncludeArchived {
+		active := bleve.NewBoolFieldQuery(false)
+		active.SetField("archived")
+		tq = bleve.NewConjunctionQuery(tq, active)
+	}
+	req := bleve.NewSearchRequestOptions(tq, limit, 0, false)
+	req.Fields = []string{"customer_id"}
+	req.IncludeLocations = true
+	var result *bleve.SearchResult
+	var err error
+	if len(indexes) == 1 {
+		result, err = indexes[0].Search(req)
+	} else {
+		result, err = bleve.NewIndexAlias(indexes...).Search(req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("searching tasks for %q: %w", q, err)
+	}
+	matches := make([]TaskMatch, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		taskID, err := uuid.Parse(hit.ID)
+		if err != nil {
+			continue
+		}
+		customerID, _ := hit.Fields["customer_id"].(string)
+		id, err := uuid.Parse(customerID)
+		if err != nil {
+			continue
+		}
+		t, ok := s.tasks[id][taskID]
+		if !ok {
+			// indexed by CustomerTasks.AddTask but not cached yet
+			continue
+		}
+		matches = append(matches, TaskMatch{Task: &t, Score: hit.Score, Highlights: highlights(hit.Locations, words, "name", "external_id")})
+	}
+	return matches, nil
+}
+
+// taskQuery builds the query run by SearchTasks for the lower cased words typed.
+func taskQuery(words []string) query.Query {
+	whole := strings.Join(words, " ")
+	exactID := bleve.NewTermQuery(whole)
+	exactID.SetField("external_id")
+	exactID.SetBoost(5)
+	prefixID := bleve.NewPrefixQuery(whole)
+	prefixID.SetField("external_id")
+	prefixID.SetBoost(3)
+
+	name := bleve.NewConjunctionQuery()
+	for _, w := range words {
+		exact := bleve.NewMatchQuery(w)
+		exact.SetField("name")
+		exact.SetBoost(3)
+		prefix := bleve.NewPrefixQuery(w)
+		prefix.SetField("name")
+		prefix.SetBoost(2)
+		word := bleve.NewDisjunctionQuery(exact, prefix)
+		// a typo in a couple of letters is more likely to be a different word
+		if len(w) >= 4 {
+			fuzzy := bleve.NewFuzzyQuery(w)
+			fuzzy.SetField("name")
+			if len(w) >= 8 {
+				fuzzy.SetFuzziness(2)
+			}
+			word.AddQuery(fuzzy)
+		}
+		name.AddQuery(word)
+	}
+	return bleve.NewDisjunctionQuery(exactID, prefixID, name)
+}
+
+// highlights turns the term locations of a hit in the given fields into highlights, terms matched
+// as a prefix are only highlighted up to what was typed.
+func highlights(locations search.FieldTermLocationMap, words []string, fields ...string) []Highlight {
+	prefixes := append([]string{strings.Join(words, " ")}, words...)
+	var hs []Highlight
+	for field, terms := range locations {
+		if !slices.Contains(fields, field) {
+			continue
+		}
+		for term, locs := range terms {
+			length := 0
+			for _, p := range prefixes {
+				if strings.HasPrefix(term, p) && len(p) > length {
+					length = len(p)
+				}
+			}
+			for _, loc := range locs {
+				h := Highlight{Field: field, Start: int(loc.Start), End: int(loc.End)}
+				if length > 0 && h.Start+length < h.End {
+					h.End = h.Start + length
+				}
+				hs = append(hs, h)
+			}
+		}
+	}
+	sort.Slice(hs, func(i, j int) bool {
+		if hs[i].Field != hs[j].Field {
+			return hs[i].Field > hs[j].Field
+		}
+		return hs[i].Start < hs[j].Start
+	})
+	return hs
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStore_SearchTasks(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	acme, globex := NewCustomer("Acme"), NewCustomer("Globex")
+	tasks := map[string]*Task{}
+	for c, defs := range map[*Customer][][2]string{
+		acme:   {{"oauth", "OAuth migration"}, {"review", "Design review"}, {"standup1", "Standup"}, {"standup2", "Standup"}},
+		globex: {{"database", "Database migration"}},
+	} {
+		if err := s.AddCustomer(c); err != nil {
+			t.Fatalf("Store.AddCustomer() error = %v", err)
+		}
+		ct, err := s.LoadTasks(c.ID)
+		if err != nil {
+			t.Fatalf("Store.LoadTasks() error = %v", err)
+		}
+		for _, def := range defs {
+			task := &Task{ID: uuid.New(), Customer: c, Name: def[1]}
+			switch def[0] {
+			case "oauth":
+				task.ExternalID = "PRJ-1234"
+			case "review":
+				task.ExternalID = "PRJ-1240"
+			}
+			if err := ct.AddTask(task); err != nil {
+				t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+			}
+			tasks[def[0]] = task
+		}
+		if err := s.SaveTasks(ct); err != nil {
+			t.Fatalf("Store.SaveTasks() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		opts           TaskSearchOptions
+		want           []string // in order unless unordered
+		unordered      bool
+		wantCount      int         // instead of want when any of the matches will do
+		wantHighlights []Highlight // of the first match
+	}{
+		{name: "prefix across customers", query: "migr", want: []string{"database", "oauth"}, unordered: true},
+		{name: "prefix in a customer", query: "migr", opts: TaskSearchOptions{CustomerID: acme.ID}, want: []string{"oauth"},
+			wantHighlights: []Highlight{{Field: "name", Start: 6, End: 10}}},
+		{name: "typo", query: "migraton", want: []string{"database", "oauth"}, unordered: true},
+		{name: "several words", query: "oauth mig", want: []string{"oauth"},
+			wantHighlights: []Highlight{{Field: "name", Start: 0, End: 5}, {Field: "name", Start: 6, End: 9}}},
+		{name: "external ID prefix", query: "PRJ-12", want: []string{"oauth", "review"}, unordered: true},
+		{name: "exact external ID", query: "prj-1240", want: []string{"review"},
+			wantHighlights: []Highlight{{Field: "external_id", Start: 0, End: 8}}},
+		{name: "same names", query: "standup", want: []string{"standup1", "standup2"}, unordered: true},
+		{name: "limit", query: "standup", opts: TaskSearchOptions{Limit: 1}, wantCount: 1},
+		{name: "nothing typed", query: "  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := s.SearchTasks(tt.query, tt.opts)
+			if err != nil {
+				t.Fatalf("Store.SearchTasks() error = %v", err)
+			}
+			var got []string
+			for _, m := range matches {
+				for key, task := range tasks {
+					if task.ID == m.Task.ID {
+						got = append(got, key)
+					}
+				}
+			}
+			if tt.wantCount > 0 {
+				if len(got) != tt.wantCount {
+					t.Errorf("Store.SearchTasks() = %v, want %d matches", got, tt.wantCount)
+				}
+				return
+			}
+			if tt.unordered {
+				sort.Strings(got)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Store.SearchTasks() = %v, want %v", got, tt.want)
+			}
+			if tt.wantHighlights != nil && !reflect.DeepEqual(matches[0].Highlights, tt.wantHighlights) {
+				t.Errorf("Store.SearchTasks() highlights = %+v, want %+v", matches[0].Highlights, tt.wantHighlights)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// sessionSlack is how far before a fragment the one preceding it in its session may start,
+// a day plus the hour DST can add to it.
+const sessionSlack = 25 * time.Hour
+
+// Session is a stretch of work on a task, it is made of a single entry unless the entry
+// was split at midnight, in which case every fragment carries the session ID.
+type Session struct {
+	ID        uuid.UUID
+	Start     time.Time
+	End       *time.Time // nil while the session is running
+	Fragments []*Entry   // sorted by start, one per day
+}
+
+// Duration returns the time worked in the session, up to now if it is still running.
+func (s *Session) Duration(now time.Time) time.Duration {
+	var total time.Duration
+	for _, e := range s.Fragments {
+		total += e.Duration(now)
+	}
+	return total
+}
+
+// NetDuration returns the time worked in the session without its breaks, up to now if it is
+// still running.
+func (s *Session) NetDuration(now time.Time) time.Duration {
+	var total time.Duration
+	for _, e := range s.Fragments {
+		total += e.NetDuration(now)
+	}
+	return total
+}
+
+// update refreshes the start and end of the session from its fragments.
+func (s *Session) update() {
+	s.Start = s.Fragments[0].StartTS
+	s.End = s.Fragments[len(s.Fragments)-1].EndTs
+}
+
+// sessionID returns the ID of the session the entry belongs to.
+func (e *Entry) sessionID() uuid.UUID {
+	if e.SessionID != uuid.Nil {
+		return e.SessionID
+	}
+	return e.ID
+}
+
+// LoadSessions returns the sessions with at least one fragment matching the filter sorted by
+// start date, each with all its fragments even those outside the filter range.
+func (s *Store) LoadSessions(f EntryFilter) ([]*Session, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	entries, err := s.LoadEntries(f)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	byID := map[uuid.UUID]*Session{}
+	for _, e := range entries {
+		id := e.sessionID()
+		session, ok := byID[id]
+		if !ok {
+			session = &Session{ID: id}
+			byID[id] = session
+			sessions = append(sessions, session)
+		}
+		session.Fragments = append(session.Fragments, e)
+	}
+	for _, session := range sessions {
+		if err := s.completeSession(session, f); err != nil {
+			return nil, fmt.Errorf("loading fragments of session %s: %w", session.ID, err)
+		}
+		session.update()
+	}
+	// completed sessions may start before the entries that matched
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Start.Before(sessions[j].Start)
+	})
+	return sessions, nil
+}
+
+// completeSession loads the fragments of a session which fall outside the filter range,
+// fragments follow each other so they are looked up one day at a time.
+func (s *Store) completeSession(session *Session, f EntryFilter) error {
+	customerID := session.Fragments[0].reference().CustomerID
+	// the first fragment is the one whose ID became the session ID
+	for session.Fragments[0].ID != session.ID {
+		first := session.Fragments[0]
+		previous, err := s.sessionFragments(session.ID, customerID, first.StartTS.Add(-sessionSlack), first.StartTS)
+		if err != nil {
+			return err
+		}
+		if len(previous) == 0 {
+			// the first fragments were deleted, keep what is left
+			break
+		}
+		session.Fragments = append(previous, session.Fragments...)
+	}
+	// a following fragment starts when the last one ends, it was already loaded unless that is past the range
+	for !f.To.IsZero() {
+		last := session.Fragments[len(session.Fragments)-1]
+		if last.EndTs == nil || last.EndTs.Before(f.To) {
+			break
+		}
+		next, err := s.sessionFragments(session.ID, customerID, *last.EndTs, last.EndTs.Add(time.Nanosecond))
+		if err != nil {
+			return err
+		}
+		if len(next) == 0 {
+			break
+		}
+		session.Fragments = append(session.Fragments, next...)
+	}
+	return nil
+}
+
+// sessionFragments returns the fragments of a session started within [from, to).
+func (s *Store) sessionFragments(sessionID, customerID uuid.UUID, from, to time.Time) ([]*Entry, error) {
+	entries, err := s.backend.LoadEntries(customerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var fragments []*Entry
+	for _, e := range entries {
+		if e.sessionID() != sessionID {
+			continue
+		}
+		if err := s.resolveEntry(e); err != nil {
+			return nil, fmt.Errorf("resolving entry %s: %w", e.ID, err)
+		}
+		fragments = append(fragments, e)
+	}
+	return fragments, nil
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestStore_LoadSessions(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	customer := NewCustomer("Test Customer")
+	if err := s.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := s.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: customer, Name: "Task"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := s.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	day := func(d, h int) time.Time {
+		return time.Date(2024, 1, d, h, 0, 0, 0, time.UTC)
+	}
+	// worked 22:00 on the 1st to 02:00 on the 4th, then 9:00 to 12:00 on the 5th
+	long := NewEntry(task, day(1, 22))
+	short := NewEntry(task, day(5, 9))
+	for _, finish := range []struct {
+		e   *Entry
+		end time.Time
+	}{{long, day(4, 2)}, {short, day(5, 12)}} {
+		if err := s.SaveEntry(finish.e); err != nil {
+			t.Fatalf("Store.SaveEntry() error = %v", err)
+		}
+		if err := s.FinishEntryAt(finish.e, finish.end); err != nil {
+			t.Fatalf("Store.FinishEntryAt() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter EntryFilter
+		want   []*Entry // the first fragment of every session
+	}{
+		{name: "everything", want: []*Entry{long, short}},
+		{name: "middle day only", filter: EntryFilter{From: day(2, 0), To: day(3, 0)}, want: []*Entry{long}},
+		{name: "last fragment only", filter: EntryFilter{From: day(4, 0), To: day(4, 12)}, want: []*Entry{long}},
+		{name: "first fragment only", filter: EntryFilter{To: day(2, 0)}, want: []*Entry{long}},
+		{name: "after", filter: EntryFilter{From: day(4, 12)}, want: []*Entry{short}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.LoadSessions(tt.filter)
+			if err != nil {
+				t.Fatalf("Store.LoadSessions() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Store.LoadSessions() = %d sessions, want %d", len(got), len(tt.want))
+			}
+			for i, session := range got {
+				if session.ID != tt.want[i].ID {
+					t.Errorf("session %d = %s, want %s", i, session.ID, tt.want[i].ID)
+				}
+			}
+			for _, session := range got {
+				if session.ID != long.ID {
+					continue
+				}
+				if len(session.Fragments) != 4 {
+					t.Errorf("session has %d fragments, want 4", len(session.Fragments))
+				}
+				if !session.Start.Equal(day(1, 22)) || session.End == nil || !session.End.Equal(day(4, 2)) {
+					t.Errorf("session spans %s to %v, want %s to %s", session.Start, session.End, day(1, 22), day(4, 2))
+				}
+				if d := session.Duration(day(5, 0)); d != 52*time.Hour {
+					t.Errorf("Session.Duration() = %s, want %s", d, 52*time.Hour)
+				}
+			}
+		})
+	}
+}
+
+func TestSession_Duration(t *testing.T) {
+	start := time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC)
+	midnight, now := start.Add(2*time.Hour), start.Add(3*time.Hour)
+	breakEnd := start.Add(3 * time.Hour / 2)
+	session := &Session{Fragments: []*Entry{
+		{StartTS: start, EndTs: &midnight, Breaks: []Break{{Start: start.Add(time.Hour), End: &breakEnd}}},
+		// still running and paused for the last quarter
+		{StartTS: midnight, Breaks: []Break{{Start: now.Add(-15 * time.Minute)}}},
+	}}
+	if got := session.Duration(now); got != 3*time.Hour {
+		t.Errorf("Session.Duration() = %v, want %v", got, 3*time.Hour)
+	}
+	if got, want := session.NetDuration(now), 3*time.Hour-45*time.Minute; got != want {
+		t.Errorf("Session.NetDuration() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrNoKey is returned when signing before a key was generated.
+	ErrNoKey = errors.New("no signing key, generate one first")
+	// ErrKeyExists is returned when generating a key while one is in use, rotate it instead.
+	ErrKeyExists = errors.New("a signing key already exists")
+	// ErrKeyElsewhere is returned when generating a key while the public one synced from another
+	// machine is in use, copy its private key here or rotate it instead.
+	ErrKeyElsewhere = errors.New("the signing key was generated on another machine, copy its private key here or rotate it")
+)
+
+// keysGitignore keeps private keys out of version control, each machine signs with its own.
+const keysGitignore = "*.key\n"
+
+// keysPath returns the folder holding the signing keys of a data root:
+//
+//	root/keys/signing.key                current private key, only readable by its owner
+//	root/keys/signing.pub                current public key
+//	root/keys/retired/{fingerprint}.pub  public keys rotated out, to check older exports
+func keysPath(root string) string {
+	return filepath.Join(root, "keys")
+}
+
+// Fingerprint identifies a public key, it is what a customer compares to trust a signature.
+func Fingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return "SHA256:" + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// SigningKey returns the current public key of the data root.
+func (s *Store) SigningKey() (ed25519.PublicKey, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return loadPublicKey(filepath.Join(keysPath(s.root), "signing.pub"))
+}
+
+// GenerateKey creates the signing key of the data root and returns the fingerprint of its
+// public half, it fails with ErrKeyExists if there is one already. The public key is versioned
+// but not the private one, so it fails with ErrKeyElsewhere when only the former is here.
+func (s *Store) GenerateKey() (string, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	dir := keysPath(s.root)
+	if _, err := os.Stat(filepath.Join(dir, "signing.key")); err == nil {
+		return "", ErrKeyExists
+	}
+	if _, err := os.Stat(filepath.Join(dir, "signing.pub")); err == nil {
+		return "", ErrKeyElsewhere
+	}
+	fingerprint, err := s.writeKey()
+	if err != nil {
+		return "", err
+	}
+	return fingerprint, s.commit("generate signing key %s", fingerprint)
+}
+
+// RotateKey replaces the signing key of the data root by a new one and returns its fingerprint,
+// the public half of the previous one is kept under keys/retired.
+func (s *Store) RotateKey() (string, error) {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	dir := keysPath(s.root)
+	previous, err := loadPublicKey(filepath.Join(dir, "signing.pub"))
+	if err != nil {
+		return "", err
+	}
+	retired := filepath.Join(dir, "retired", Fingerprint(previous)[len("SHA256:"):]+".pub")
+	if err := os.MkdirAll(filepath.Dir(retired), os.ModePerm); err != nil {
+		return "", fmt.Errorf("could not create directory %s: %w", filepath.Dir(retired), err)
+	}
+	if err := writePEM(retired, 0644, "PUBLIC KEY", previous); err != nil {
+		return "", err
+	}
+	fingerprint, err := s.writeKey()
+	if err != nil {
+		return "", err
+	}
+	return fingerprint, s.commit("rotate signing key %s to %s", Fingerprint(previous), fingerprint)
+}
+
+// RetiredKeys returns the public keys rotated out of the data root.
+func (s *Store) RetiredKeys() ([]ed25519.PublicKey, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	paths, err := filepath.Glob(filepath.Join(keysPath(s.root), "retired", "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]ed25519.PublicKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// writeKey generates a key pair and writes it over the current one, the root lock must be held exclusively.
+func (s *Store) writeKey() (string, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("generating signing key: %w", err)
+	}
+	dir := keysPath(s.root)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("could not create directory %s: %w", dir, err)
+	}
+	if err := writeIfMissing(filepath.Join(dir, ".gitignore"), keysGitignore); err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("encoding signing key: %w", err)
+	}
+	// the private key goes first, a public key without it would look usable
+	if err := writePEM(filepath.Join(dir, "signing.key"), 0600, "PRIVATE KEY", der); err != nil {
+		return "", err
+	}
+	if err := writePEM(filepath.Join(dir, "signing.pub"), 0644, "PUBLIC KEY", public); err != nil {
+		return "", err
+	}
+	return Fingerprint(public), nil
+}
+
+// signingKey loads the current private key, the root lock must be held.
+func (s *Store) signingKey() (ed25519.PrivateKey, error) {
+	der, err := readPEM(filepath.Join(keysPath(s.root), "signing.key"), "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signing key: %w", err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is a %T, want ed25519", key)
+	}
+	return private, nil
+}
+
+// loadPublicKey reads a public key written by writeKey.
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key %s has %d bytes, want %d", path, len(data), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// readPEM returns the content of the single PEM block of the given type in path.
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoKey
+		}
+		return nil, fmt.Errorf("reading key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s does not hold a %s", path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+// writePEM atomically writes data as a PEM block of the given type.
+func writePEM(path string, perm os.FileMode, blockType string, data []byte) error {
+	return writeFileAtomicMode(path, perm, func(w io.Writer) error {
+		return pem.Encode(w, &pem.Block{
+			Type:    blockType,
+			Headers: map[string]string{"Created": time.Now().UTC().Format(time.RFC3339)},
+			Bytes:   data,
+		})
+	})
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// midnights returns the midnights in loc strictly within (start, end), in order.
+func midnights(start, end time.Time, loc *time.Location) []time.Time {
+	var cuts []time.Time
+	// walk calendar days at noon, which always exists, DST can make a midnight skip or repeat
+	s := start.In(loc)
+	day := time.Date(s.Year(), s.Month(), s.Day(), 12, 0, 0, 0, loc)
+	for {
+		day = day.AddDate(0, 0, 1)
+		midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+		if !midnight.Before(end) {
+			return cuts
+		}
+		if midnight.After(start) {
+			cuts = append(cuts, midnight)
+		}
+	}
+}
+
+// splitEntry ends e at end and returns it along with the fragments needed so that none crosses
+// midnight in loc. e becomes the first fragment and keeps its ID, the others get their own and
+// all of them share e's ID as SessionID. An entry that does not cross midnight is returned alone.
+func splitEntry(e *Entry, end time.Time, loc *time.Location) ([]*Entry, error) {
+	if end.Before(e.StartTS) {
+		return nil, fmt.Errorf("entry %s cannot end at %s, before its start at %s", e.ID, end, e.StartTS)
+	}
+	// a pause still going on ends with the entry
+	breaks := e.Breaks
+	cuts := midnights(e.StartTS, end, loc)
+	if len(cuts) == 0 {
+		e.EndTs = &end
+		e.Breaks = clipBreaks(breaks, e.StartTS, end)
+		return []*Entry{e}, nil
+	}
+	if e.SessionID == uuid.Nil {
+		e.SessionID = e.ID
+	}
+	firstEnd := cuts[0]
+	e.EndTs = &firstEnd
+	e.Breaks = clipBreaks(breaks, e.StartTS, firstEnd)
+	fragments := []*Entry{e}
+	for i, start := range cuts {
+		fragmentEnd := end
+		if i+1 < len(cuts) {
+			fragmentEnd = cuts[i+1]
+		}
+		fragments = append(fragments, &Entry{
+			ID:        uuid.New(),
+			Task:      e.Task,
+			Comment:   e.Comment,
+			StartTS:   start,
+			EndTs:     &fragmentEnd,
+			Tags:      append([]string(nil), e.Tags...),
+			Breaks:    clipBreaks(breaks, start, fragmentEnd),
+			SessionID: e.SessionID,
+			ref:       e.ref,
+		})
+	}
+	return fragments, nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestSplitEntry(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		start   time.Time
+		end     time.Time
+		loc     *time.Location
+		want    []time.Duration // duration of every fragment
+		wantErr bool
+	}{
+		{
+			name:  "same day",
+			start: time.Date(2024, 5, 2, 9, 0, 0, 0, berlin),
+			end:   time.Date(2024, 5, 2, 17, 30, 0, 0, berlin),
+			loc:   berlin,
+			want:  []time.Duration{8*time.Hour + 30*time.Minute},
+		},
+		{
+			name:  "ends at midnight",
+			start: time.Date(2024, 5, 2, 22, 0, 0, 0, berlin),
+			end:   time.Date(2024, 5, 3, 0, 0, 0, 0, berlin),
+			loc:   berlin,
+			want:  []time.Duration{2 * time.Hour},
+		},
+		{
+			name:  "overnight",
+			start: time.Date(2024, 5, 2, 22, 0, 0, 0, berlin),
+			end:   time.Date(2024, 5, 3, 2, 0, 0, 0, berlin),
+			loc:   berlin,
+			want:  []time.Duration{2 * time.Hour, 2 * time.Hour},
+		},
+		{
+			name:  "several days",
+			start: time.Date(2024, 5, 2, 18, 0, 0, 0, berlin),
+			end:   time.Date(2024, 5, 5, 6, 0, 0, 0, berlin),
+			loc:   berlin,
+			want:  []time.Duration{6 * time.Hour, 24 * time.Hour, 24 * time.Hour, 6 * time.Hour},
+		},
+		{
+			name:  "spring forward day is 23 hours",
+			start: time.Date(2024, 3, 30, 20, 0, 0, 0, berlin),
+			end:   time.Date(2024, 4, 1, 1, 0, 0, 0, berlin),
+			loc:   berlin,
+			want:  []time.Duration{4 * time.Hour, 23 * time.Hour, time.Hour},
+		},
+		{
+			name:  "fall back day is 25 hours",
+			start: time.Date(2024, 10, 26, 20, 0, 0, 0, berlin),
+			end:   time.Date(2024, 10, 28, 1, 0, 0, 0, berlin),
+			loc:   berlin,
+			want:  []time.Duration{4 * time.Hour, 25 * time.Hour, time.Hour},
+		},
+		{
+			name:  "year change",
+			start: time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC),
+			loc:   time.UTC,
+			want:  []time.Duration{time.Hour, time.Hour},
+		},
+		{
+			name:  "configured timezone wins over the start one",
+			start: time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 5, 2, 16, 0, 0, 0, time.UTC),
+			loc:   tokyo,
+			want:  []time.Duration{time.Hour, time.Hour},
+		},
+		{
+			name:    "end before start",
+			start:   time.Date(2024, 5, 2, 9, 0, 0, 0, berlin),
+			end:     time.Date(2024, 5, 2, 8, 0, 0, 0, berlin),
+			loc:     berlin,
+			wantErr: true,
+		},
+	}
+	task := &Task{ID: uuid.New(), Customer: NewCustomer("Test Customer"), Name: "Task"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEntry(task, tt.start)
+			got, err := splitEntry(e, tt.end, tt.loc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("splitEntry() = %d fragments, want %d", len(got), len(tt.want))
+			}
+			if len(got) > 0 && got[0] != e {
+				t.Errorf("splitEntry() first fragment is not the entry itself")
+			}
+			ids := map[uuid.UUID]bool{}
+			for i, f := range got {
+				if d := f.EndTs.Sub(f.StartTS); d != tt.want[i] {
+					t.Errorf("fragment %d lasts %s, want %s", i, d, tt.want[i])
+				}
+				if i > 0 {
+					if !f.StartTS.Equal(*got[i-1].EndTs) {
+						t.Errorf("fragment %d starts at %s, want %s", i, f.StartTS, got[i-1].EndTs)
+					}
+					if local := f.StartTS.In(tt.loc); local.Hour() != 0 || local.Minute() != 0 {
+						t.Errorf("fragment %d starts at %s, want midnight", i, local)
+					}
+				}
+				wantSession := uuid.Nil
+				if len(got) > 1 {
+					wantSession = e.ID
+				}
+				if f.SessionID != wantSession {
+					t.Errorf("fragment %d session = %s, want %s", i, f.SessionID, wantSession)
+				}
+				ids[f.ID] = true
+			}
+			if len(ids) != len(got) {
+				t.Errorf("splitEntry() fragments share IDs")
+			}
+			if len(got) > 0 && !got[len(got)-1].EndTs.Equal(tt.end) {
+				t.Errorf("splitEntry() ends at %s, want %s", got[len(got)-1].EndTs, tt.end)
+			}
+		})
+	}
+}
+
+func TestStore_FinishEntryAt(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	customer := NewCustomer("Test Customer")
+	if err := s.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := s.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: customer, Name: "Task"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := s.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	e := NewEntry(task, time.Date(2023, 12, 31, 22, 0, 0, 0, time.UTC))
+	if err := s.SaveEntry(e); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	if err := s.FinishEntryAt(e, time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Store.FinishEntryAt() error = %v", err)
+	}
+	got, err := s.LoadEntries(EntryFilter{CustomerID: customer.ID})
+	if err != nil {
+		t.Fatalf("Store.LoadEntries() error = %v", err)
+	}
+	if len(got) != 2 || got[0].ID != e.ID || got[1].SessionID != e.ID || got[0].SessionID != e.ID {
+		t.Fatalf("Store.LoadEntries() = %v, want the entry and its fragment of the same session", got)
+	}
+	if !got[1].StartTS.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || got[1].EndTs == nil {
+		t.Errorf("fragment = %v, want it to start on new year", got[1])
+	}
+}
@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+// sqliteFileName is the name of the database file in the data root when the sqlite backend is used.
+const sqliteFileName = "ballandchain.db"
+
+// sqliteSchema mirrors the JSON layout, every row keeps the JSON document in data so
+// conversions are lossless while the other columns are there to be queried.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS customers (
+	id   TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS tasks (
+	customer_id TEXT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+	id          TEXT NOT NULL,
+	position    INTEGER NOT NULL,
+	external_id TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	data        TEXT NOT NULL,
+	PRIMARY KEY (customer_id, id)
+);
+CREATE TABLE IF NOT EXISTS entries (
+	id          TEXT PRIMARY KEY,
+	customer_id TEXT NOT NULL,
+	task_id     TEXT NOT NULL,
+	start_ts    INTEGER NOT NULL,
+	end_ts      INTEGER,
+	data        TEXT NOT NULL,
+	FOREIGN KEY (customer_id, task_id) REFERENCES tasks(customer_id, id)
+);
+CREATE INDEX IF NOT EXISTS entries_customer_start ON entries(customer_id, start_ts);
+CREATE INDEX IF NOT EXISTS entries_start ON entries(start_ts);
+CREATE INDEX IF NOT EXISTS entries_end ON entries(end_ts);
+`
+
+// SQLiteBackend stores everything in a single sqlite database file.
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// OpenSQLiteBackend opens, creating it if needed, the sqlite database at path.
+func OpenSQLiteBackend(path string) (*SQLiteBackend, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating sqlite schema: %w", err)
+	}
+	return &SQLiteBackend{db: db}, nil
+}
+
+// SaveCustomer implements Backend.
+func (b *SQLiteBackend) SaveCustomer(c *Customer) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encode customer: %w", err)
+	}
+	_, err = b.db.Exec(`INSERT INTO customers (id, name, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, data = excluded.data`,
+		c.ID.String(), c.Name, string(data))
+	if err != nil {
+		return fmt.Errorf("save customer: %w", err)
+	}
+	return nil
+}
+
+// LoadCustomer implements Backend.
+func (b *SQLiteBackend) LoadCustomer(id uuid.UUID) (*Customer, error) {
+	var data string
+	err := b.db.QueryRow(`SELECT data FROM customers WHERE id = ?`, id.String()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("customer %s does not exist: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load customer: %w", err)
+	}
+	c := &Customer{}
+	if err := json.Unmarshal([]byte(data), c); err != nil {
+		return nil, fmt.Errorf("decode customer: %w", err)
+	}
+	return c, nil
+}
+
+// LoadCustomers implements Backend.
+func (b *SQLiteBackend) LoadCustomers() ([]Customer, error) {
+	rows, err := b.db.Query(`SELECT data FROM customers ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("query customers: %w", err)
+	}
+	defer rows.Close()
+	var customers []Customer
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("scan customer: %w", err)
+		}
+		var c Customer
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			return nil, fmt.Errorf("decode customer: %w", err)
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+// DeleteCustomer implements Backend.
+func (b *SQLiteBackend) DeleteCustomer(id uuid.UUID) error {
+	return b.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM entries WHERE customer_id = ?`, id.String()); err != nil {
+			return fmt.Errorf("delete customer entries: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM customers WHERE id = ?`, id.String()); err != nil {
+			return fmt.Errorf("delete customer: %w", err)
+		}
+		return nil
+	})
+}
+
+// SaveTasks implements Backend.
+func (b *SQLiteBackend) SaveTasks(ct *CustomerTasks) error {
+	return b.inTx(func(tx *sql.Tx) error {
+		customerID := ct.Customer.ID.String()
+		if _, err := tx.Exec(`CREATE TEMP TABLE IF NOT EXISTS kept_tasks (id TEXT PRIMARY KEY)`); err != nil {
+			return fmt.Errorf("create kept tasks table: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM kept_tasks`); err != nil {
+			return fmt.Errorf("clear kept tasks table: %w", err)
+		}
+		for i, t := range ct.Tasks {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return fmt.Errorf("encode task %s: %w", t.ID, err)
+			}
+			_, err = tx.Exec(`INSERT INTO tasks (customer_id, id, position, external_id, name, data) VALUES (?, ?, ?, ?, ?, ?)
+				ON CONFLICT (customer_id, id) DO UPDATE SET position = excluded.position,
+				external_id = excluded.external_id, name = excluded.name, data = excluded.data`,
+				customerID, t.ID.String(), i, t.ExternalID, t.Name, string(data))
+			if err != nil {
+				return fmt.Errorf("save task %s: %w", t.ID, err)
+			}
+			if _, err := tx.Exec(`INSERT INTO kept_tasks (id) VALUES (?)`, t.ID.String()); err != nil {
+				return fmt.Errorf("keep task %s: %w", t.ID, err)
+			}
+		}
+		_, err := tx.Exec(`DELETE FROM tasks WHERE customer_id = ? AND id NOT IN (SELECT id FROM kept_tasks)`, customerID)
+		if err != nil {
+			return fmt.Errorf("delete removed tasks: %w", err)
+		}
+		return nil
+	})
+}
+
+// LoadTasks implements Backend.
+func (b *SQLiteBackend) LoadTasks(c *Customer) (*CustomerTasks, error) {
+	rows, err := b.db.Query(`SELECT data FROM tasks WHERE customer_id = ? ORDER BY position`, c.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("query tasks: %w", err)
+	}
+	defer rows.Close()
+	ct := &CustomerTasks{
+		Customer: c,
+		Tasks:    []*Task{},
+	}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("scan task: %w", err)
+		}
+		t := &Task{}
+		if err := json.Unmarshal([]byte(data), t); err != nil {
+			return nil, fmt.Errorf("decode task: %w", err)
+		}
+		t.Customer = c
+		ct.Tasks = append(ct.Tasks, t)
+	}
+	return ct, rows.Err()
+}
+
+// SaveEntry implements Backend.
+func (b *SQLiteBackend) SaveEntry(e *Entry) error {
+	return b.inTx(func(tx *sql.Tx) error {
+		return saveSQLiteEntry(tx, e)
+	})
+}
+
+// SaveEntries implements Backend, all entries are saved in one transaction.
+func (b *SQLiteBackend) SaveEntries(entries ...*Entry) error {
+	return b.inTx(func(tx *sql.Tx) error {
+		for _, e := range entries {
+			if err := saveSQLiteEntry(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveSQLiteEntry upserts an entry within the given transaction.
+func saveSQLiteEntry(tx *sql.Tx, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode entry %s: %w", e.ID, err)
+	}
+	var endTS sql.NullInt64
+	if e.EndTs != nil {
+		endTS = sql.NullInt64{Int64: e.EndTs.UnixNano(), Valid: true}
+	}
+	ref := e.reference()
+	_, err = tx.Exec(`INSERT INTO entries (id, customer_id, task_id, start_ts, end_ts, data) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET customer_id = excluded.customer_id, task_id = excluded.task_id,
+		start_ts = excluded.start_ts, end_ts = excluded.end_ts, data = excluded.data`,
+		e.ID.String(), ref.CustomerID.String(), ref.TaskID.String(), e.StartTS.UnixNano(), endTS, string(data))
+	if err != nil {
+		return fmt.Errorf("save entry %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+// DeleteEntry implements Backend.
+func (b *SQLiteBackend) DeleteEntry(e *Entry) error {
+	res, err := b.db.Exec(`DELETE FROM entries WHERE id = ?`, e.ID.String())
+	if err != nil {
+		return fmt.Errorf("delete entry %s: %w", e.ID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("entry %s: %w", e.ID, ErrNotFound)
+	}
+	return nil
+}
+
+// sqliteBounds turns a [from, to) range into start_ts bounds, zero times being unbounded.
+func sqliteBounds(from, to time.Time) (int64, int64) {
+	lower, upper := int64(math.MinInt64), int64(math.MaxInt64)
+	if !from.IsZero() {
+		lower = from.UnixNano()
+	}
+	if !to.IsZero() {
+		upper = to.UnixNano()
+	}
+	return lower, upper
+}
+
+// LoadEntries implements Backend, it is a single query on the customer and start index.
+func (b *SQLiteBackend) LoadEntries(customerID uuid.UUID, from, to time.Time) ([]*Entry, error) {
+	lower, upper := sqliteBounds(from, to)
+	rows, err := b.db.Query(`SELECT data FROM entries WHERE customer_id = ? AND start_ts >= ? AND start_ts < ?
+		ORDER BY start_ts`, customerID.String(), lower, upper)
+	if err != nil {
+		return nil, fmt.Errorf("query entries: %w", err)
+	}
+	defer rows.Close()
+	var entries []*Entry
+	for rows.Next() {
+		e, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// WalkEntries implements Backend, it pages through the customer and start index so no
+// statement stays open while fn runs.
+func (b *SQLiteBackend) WalkEntries(ctx context.Context, customerID uuid.UUID, from, to time.Time, fn func(e *Entry) error) error {
+	lower, upper := sqliteBounds(from, to)
+	afterID := ""
+	for {
+		batch, err := b.entryBatch(ctx, customerID, lower, afterID, upper)
+		if err != nil {
+			return err
+		}
+		for _, e := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(e); err != nil {
+				return walkResult(err)
+			}
+		}
+		if len(batch) < walkBatchSize {
+			return nil
+		}
+		last := batch[len(batch)-1]
+		lower, afterID = last.StartTS.UnixNano(), last.ID.String()
+	}
+}
+
+// entryBatch returns the next walkBatchSize entries started before upper and after the entry
+// started at lower with ID afterID, an empty afterID includes all entries started at lower.
+func (b *SQLiteBackend) entryBatch(ctx context.Context, customerID uuid.UUID, lower int64, afterID string, upper int64) ([]*Entry, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT data FROM entries WHERE customer_id = ?
+		AND (start_ts > ? OR (start_ts = ? AND id > ?)) AND start_ts < ?
+		ORDER BY start_ts, id LIMIT ?`, customerID.String(), lower, lower, afterID, upper, walkBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("query entries: %w", err)
+	}
+	defer rows.Close()
+	var entries []*Entry
+	for rows.Next() {
+		e, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// LastEntry implements Backend.
+func (b *SQLiteBackend) LastEntry(customerID uuid.UUID) (*Entry, error) {
+	rows, err := b.db.Query(`SELECT data FROM entries WHERE customer_id = ? ORDER BY start_ts DESC LIMIT 1`, customerID.String())
+	if err != nil {
+		return nil, fmt.Errorf("query last entry: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("query last entry: %w", err)
+		}
+		return nil, fmt.Errorf("no entries for customer %s: %w", customerID, ErrNotFound)
+	}
+	return scanSQLiteEntry(rows)
+}
+
+// scanSQLiteEntry decodes the data column of the current row.
+func scanSQLiteEntry(rows *sql.Rows) (*Entry, error) {
+	var data string
+	if err := rows.Scan(&data); err != nil {
+		return nil, fmt.Errorf("scan entry: %w", err)
+	}
+	e := &Entry{}
+	if err := json.Unmarshal([]byte(data), e); err != nil {
+		return nil, fmt.Errorf("decode entry: %w", err)
+	}
+	return e, nil
+}
+
+// Close implements Backend.
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
+
+// inTx runs fn in a transaction, committing it only if fn succeeds.
+func (b *SQLiteBackend) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
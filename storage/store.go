@@ -0,0 +1,579 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blevesearch/bleve"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures how a Store is opened, the zero value is ready to use.
+type Options struct {
+	// Backend persists the data, it defaults to the one selected by the root config.
+	Backend Backend
+	// LockTimeout is how long to wait for other processes using the root, it defaults to DefaultLockTimeout.
+	LockTimeout time.Duration
+	// Location is the timezone whose midnights split entries into days, it defaults to the one of the root config.
+	Location *time.Location
+	// VersionControl commits the data root to git after every change, it is also enabled by the root config.
+	VersionControl bool
+	// Online syncs the data root with its git remote on open and after every change, it implies
+	// VersionControl and is also enabled by the root config.
+	Online bool
+	// Remote is the URL of the git remote to sync with, it defaults to the one of the root config.
+	Remote string
+	// ParallelTimers lets several timers run at once instead of one finishing the other, it is
+	// also enabled by the root config.
+	ParallelTimers bool
+	// Warn is called with the problems the store works around, such as failing to sync while
+	// offline, they are ignored when nil.
+	Warn func(err error)
+}
+
+// Store owns a data root along with the caches and indexes built from it.
+// Several stores can be open at the same time as long as their roots differ.
+// A Store is safe for concurrent use.
+type Store struct {
+	root        string
+	lockTimeout time.Duration
+	backend     Backend
+	location    *time.Location
+	git         *gitRepo // nil without version control
+	online      bool
+	// parallelTimers lets several entries run at once, see Start
+	parallelTimers bool
+	onWarning      func(err error) // see Options.Warn
+
+	mu           sync.RWMutex // guards the fields below
+	customers    map[uuid.UUID]Customer
+	tasks        map[uuid.UUID]map[uuid.UUID]Task // this does not take in account possible clashes
+	taskIndex    map[uuid.UUID]bleve.Index        // index with this https://github.com/blevesearch/bleve
+	indexLock    *os.File                         // held while the indexes are stored under the root, nil if in memory
+	commentIndex bleve.Index                      // opened on first use, see comments
+	leftovers    []string                         // temporary files of interrupted writes removed on open
+	closed       bool
+}
+
+// DefaultRoot returns the data root to use when none was given, it honors BAC_ROOT_FOLDER
+// and falls back to ~/.ballandchain
+func DefaultRoot() (string, error) {
+	rootFolder := os.Getenv("BAC_ROOT_FOLDER")
+	if rootFolder != "" {
+		return rootFolder, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+	return filepath.Join(home, ".ballandchain"), nil
+}
+
+// Open loads all customers and tasks found in root and indexes them, it first cleans up
+// after writes interrupted by a crash, see RecoverTempFiles.
+func Open(root string, opts Options) (*Store, error) {
+	s := &Store{
+		root:        root,
+		lockTimeout: opts.LockTimeout,
+		backend:     opts.Backend,
+		location:    opts.Location,
+		onWarning:   opts.Warn,
+	}
+	if s.lockTimeout == 0 {
+		s.lockTimeout = DefaultLockTimeout
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if s.location == nil {
+		if s.location, err = cfg.Location(); err != nil {
+			return nil, err
+		}
+	}
+	if s.backend == nil {
+		s.backend, err = cfg.OpenBackend(root)
+		if err != nil {
+			return nil, fmt.Errorf("opening backend: %w", err)
+		}
+	}
+	s.online = opts.Online || cfg.Online
+	s.parallelTimers = opts.ParallelTimers || cfg.ParallelTimers
+	remote := opts.Remote
+	if remote == "" {
+		remote = cfg.Remote
+	}
+	if opts.VersionControl || cfg.VersionControl || s.online {
+		if err := s.openGitRepo(remote); err != nil {
+			s.backend.Close()
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.lockIndexes(); err != nil {
+		s.closeLocked()
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		s.closeLocked()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load fills the caches from the backend and opens the indexes, s.mu must be held.
+func (s *Store) load() error {
+	customers, err := s.backend.LoadCustomers()
+	if err != nil {
+		return fmt.Errorf("loading customers: %w", err)
+	}
+	s.customers = make(map[uuid.UUID]Customer, len(customers))
+	s.tasks = make(map[uuid.UUID]map[uuid.UUID]Task, len(customers))
+	s.taskIndex = make(map[uuid.UUID]bleve.Index, len(customers))
+	for i := range customers {
+		customer := &customers[i]
+		// always populate customers before loading tasks for that customer
+		s.customers[customer.ID] = *customer
+		cTasks, err := s.backend.LoadTasks(customer)
+		if err != nil {
+			return fmt.Errorf("loading tasks for customer %s: %w", customer.Name, err)
+		}
+		index, err := s.openTaskIndex(customer, cTasks.Tasks)
+		if err != nil {
+			return err
+		}
+		s.taskIndex[customer.ID] = index
+		s.setTasks(customer, cTasks.Tasks)
+	}
+	return nil
+}
+
+// setTasks replaces the cached tasks of a customer, s.mu must be held.
+func (s *Store) setTasks(c *Customer, tasks []*Task) {
+	owner := *c
+	cached := make(map[uuid.UUID]Task, len(tasks))
+	for _, t := range tasks {
+		task := *t
+		task.Customer = &owner
+		cached[t.ID] = task
+	}
+	s.tasks[c.ID] = cached
+}
+
+// setCustomer replaces a cached customer along with the copy of it every cached task of its
+// holds, s.mu must be held.
+func (s *Store) setCustomer(c *Customer) {
+	s.customers[c.ID] = *c
+	owner := *c
+	for id, t := range s.tasks[c.ID] {
+		t.Customer = &owner
+		s.tasks[c.ID][id] = t
+	}
+}
+
+// reload drops the caches and indexes and loads them again from the backend.
+func (s *Store) reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, index := range s.taskIndex {
+		index.Close()
+	}
+	// entries changed without going through the ledger, index their comments from scratch
+	if err := s.closeComments(true); err != nil {
+		return err
+	}
+	return s.load()
+}
+
+// openGitRepo enables version control, the repository is created on first use. Online stores
+// point it to remote, when given, and sync before anything gets loaded.
+func (s *Store) openGitRepo(remote string) error {
+	// initializing and syncing write to the root
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if s.git, err = openGitRepo(s.root); err != nil {
+		return err
+	}
+	if remote != "" {
+		if err := s.git.setRemote(remote); err != nil {
+			return err
+		}
+	}
+	if !s.online {
+		return nil
+	}
+	if !s.git.hasRemote() {
+		return fmt.Errorf("syncing %s: no remote configured", s.root)
+	}
+	// being offline must not prevent working, the next sync catches up
+	changed, merged, err := s.git.pull()
+	if err != nil {
+		s.warn(fmt.Errorf("could not pull %s, working offline: %w", s.root, err))
+		return nil
+	}
+	if changed {
+		if err := s.settle(merged); err != nil {
+			return err
+		}
+	}
+	if err := s.git.push(); err != nil {
+		s.warn(fmt.Errorf("could not push %s, working offline: %w", s.root, err))
+	}
+	return nil
+}
+
+// warn reports a problem the store works around, see Options.Warn.
+func (s *Store) warn(err error) {
+	if s.onWarning != nil {
+		s.onWarning(err)
+	}
+}
+
+// recover removes the leftovers of interrupted writes, holding the root lock so no live write is
+// mistaken for one, and moves the entries of roots using the former layout.
+func (s *Store) recover() error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if s.leftovers, err = RecoverTempFiles(s.root); err != nil {
+		return err
+	}
+	if err := migrateEntryLayout(s.root); err != nil {
+		return fmt.Errorf("moving entries to their customer folders: %w", err)
+	}
+	return nil
+}
+
+// lock takes the data root lock shared by other processes, see lockRoot.
+func (s *Store) lock(exclusive bool) (func(), error) {
+	return lockRoot(s.root, exclusive, s.lockTimeout)
+}
+
+// ensureIndex returns the task index for the given customer creating it if needed, s.mu must be held.
+func (s *Store) ensureIndex(c *Customer) (bleve.Index, error) {
+	if index, ok := s.taskIndex[c.ID]; ok {
+		return index, nil
+	}
+	index, err := s.openTaskIndex(c, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.taskIndex[c.ID] = index
+	return index, nil
+}
+
+// Root returns the data root this store was opened for.
+func (s *Store) Root() string {
+	return s.root
+}
+
+// Leftovers returns the temporary files of interrupted writes that were removed when opening the store.
+func (s *Store) Leftovers() []string {
+	return s.leftovers
+}
+
+// Location returns the timezone whose midnights split entries into days.
+func (s *Store) Location() *time.Location {
+	return s.location
+}
+
+// Backend returns the backend the store persists to.
+func (s *Store) Backend() Backend {
+	return s.backend
+}
+
+// Close releases the indexes and the backend held by the store, it is safe to call more than once.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeLocked()
+}
+
+// closeLocked is Close with s.mu held.
+func (s *Store) closeLocked() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	var errs []error
+	for id, index := range s.taskIndex {
+		if err := index.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing index for customer %s: %w", id, err))
+		}
+	}
+	if err := s.closeComments(false); err != nil {
+		errs = append(errs, err)
+	}
+	s.unlockIndexes()
+	if err := s.backend.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing backend: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Customer returns the cached customer with the given ID.
+func (s *Store) Customer(id uuid.UUID) (*Customer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.customers[id]
+	if !ok {
+		return nil, fmt.Errorf("customer %s: %w", id, ErrNotFound)
+	}
+	return &c, nil
+}
+
+// Customers returns the cached customers sorted by name.
+func (s *Store) Customers() []Customer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	customers := make([]Customer, 0, len(s.customers))
+	for _, c := range s.customers {
+		customers = append(customers, c)
+	}
+	sort.Slice(customers, func(i, j int) bool {
+		return customers[i].Name < customers[j].Name
+	})
+	return customers
+}
+
+// FindCustomer returns the cached customer with the given ID or, failing that, the only one with
+// the given name ignoring case.
+func (s *Store) FindCustomer(idOrName string) (*Customer, error) {
+	if id, err := uuid.Parse(idOrName); err == nil {
+		return s.Customer(id)
+	}
+	var found []Customer
+	for _, c := range s.Customers() {
+		if strings.EqualFold(c.Name, idOrName) {
+			found = append(found, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("customer %q: %w", idOrName, ErrNotFound)
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, fmt.Errorf("%d customers named %q, use the ID", len(found), idOrName)
+	}
+}
+
+// FindTask returns the cached task of a customer with the given ID or external ID or, failing
+// that, the only one with the given name, both ignoring case.
+func (s *Store) FindTask(customerID uuid.UUID, ref string) (*Task, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return s.Task(customerID, id)
+	}
+	s.mu.RLock()
+	tasks, ok := s.tasks[customerID]
+	var byExternalID, byName []Task
+	for _, t := range tasks {
+		if t.ExternalID != "" && strings.EqualFold(t.ExternalID, ref) {
+			byExternalID = append(byExternalID, t)
+		}
+		if strings.EqualFold(t.Name, ref) {
+			byName = append(byName, t)
+		}
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("customer %s: %w", customerID, ErrNotFound)
+	}
+	for _, found := range [][]Task{byExternalID, byName} {
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return &found[0], nil
+		default:
+			return nil, fmt.Errorf("%d tasks match %q, use the ID", len(found), ref)
+		}
+	}
+	return nil, fmt.Errorf("task %q: %w", ref, ErrNotFound)
+}
+
+// Task returns the cached task with the given ID for the given customer.
+func (s *Store) Task(customerID, taskID uuid.UUID) (*Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.tasks[customerID]
+	if !ok {
+		return nil, fmt.Errorf("could not find customer ID in task list %q", customerID)
+	}
+	t, ok := c[taskID]
+	if !ok {
+		return nil, fmt.Errorf("could not find task %q", taskID)
+	}
+	return &t, nil
+}
+
+// AddCustomer persists a new customer and makes it known to the store.
+func (s *Store) AddCustomer(c *Customer) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.backend.SaveCustomer(c); err != nil {
+		return fmt.Errorf("saving customer %s: %w", c.Name, err)
+	}
+	if err := s.commit("save customer %s", c.Name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.ensureIndex(c); err != nil {
+		return err
+	}
+	s.customers[c.ID] = *c
+	if _, ok := s.tasks[c.ID]; !ok {
+		s.tasks[c.ID] = map[uuid.UUID]Task{}
+	}
+	return nil
+}
+
+// LoadTasks reads the tasks of a customer known to this store.
+func (s *Store) LoadTasks(customerID uuid.UUID) (*CustomerTasks, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	c, err := s.Customer(customerID)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := s.backend.LoadTasks(c)
+	if err != nil {
+		return nil, fmt.Errorf("loading tasks for customer %s: %w", c.Name, err)
+	}
+	ct.store = s
+	return ct, nil
+}
+
+// SaveTasks persists the tasks of a customer.
+func (s *Store) SaveTasks(ct *CustomerTasks) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.saveTasks(ct, fmt.Sprintf("save %d tasks for %s", len(ct.Tasks), ct.Customer.Name))
+}
+
+// saveTasks persists the tasks of a customer, indexes and caches them and commits with the given
+// message, the root lock must be held exclusively.
+func (s *Store) saveTasks(ct *CustomerTasks, message string) error {
+	if err := s.backend.SaveTasks(ct); err != nil {
+		return fmt.Errorf("saving tasks for customer %s: %w", ct.Customer.Name, err)
+	}
+	if err := s.refreshTaskIndex(ct.Customer, ct.Tasks); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.setTasks(ct.Customer, ct.Tasks)
+	s.mu.Unlock()
+	return s.commit("%s", message)
+}
+
+// DeleteCustomer removes a customer along with its tasks, entries and ledger.
+func (s *Store) DeleteCustomer(id uuid.UUID) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	name := id.String()
+	if c, err := s.Customer(id); err == nil {
+		name = c.Name
+	}
+	if err := s.backend.DeleteCustomer(id); err != nil {
+		return fmt.Errorf("deleting customer %s: %w", id, err)
+	}
+	if err := os.Remove(ledgerPath(s.root, id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing ledger of customer %s: %w", id, err)
+	}
+	if err := s.forgetActive(id); err != nil {
+		return err
+	}
+	if err := s.commit("delete customer %s", name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.removeTaskIndex(id); err != nil {
+		return err
+	}
+	if s.commentIndex != nil {
+		if err := deleteCustomerComments(s.commentIndex, id); err != nil {
+			return err
+		}
+		if err := s.commentIndex.DeleteInternal(headKey(id)); err != nil {
+			return fmt.Errorf("removing comment index head of customer %s: %w", id, err)
+		}
+	}
+	delete(s.tasks, id)
+	delete(s.customers, id)
+	return nil
+}
+
+// cacheTask makes a task added to a customer known to the store and indexes it for search.
+func (s *Store) cacheTask(c *Customer, t *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, err := s.ensureIndex(c)
+	if err != nil {
+		return err
+	}
+	err = index.Index(t.ID.String(), newTaskDocument(c, t))
+	if err != nil {
+		return fmt.Errorf("indexing task %s for customer %s: %w", t.Name, c.Name, err)
+	}
+	tasks, ok := s.tasks[c.ID]
+	if !ok {
+		tasks = map[uuid.UUID]Task{}
+		s.tasks[c.ID] = tasks
+	}
+	tasks[t.ID] = *t
+	return nil
+}
+
+// resolveEntry binds the task reference of a decoded entry to the cached task. A task missing
+// from the cache is looked up in the backend once, it may have been added behind the store by
+// another process.
+func (s *Store) resolveEntry(e *Entry) error {
+	ref := e.reference()
+	task, err := s.Task(ref.CustomerID, ref.TaskID)
+	if err != nil {
+		if _, _, err := s.applyCustomer(ref.CustomerID); err != nil {
+			return err
+		}
+		if _, _, err := s.applyTasks(ref.CustomerID); err != nil {
+			return err
+		}
+		if task, err = s.Task(ref.CustomerID, ref.TaskID); err != nil {
+			return err
+		}
+	}
+	e.Task = task
+	return nil
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpen(t *testing.T) {
+	tmpFolder := t.TempDir()
+	customer := Customer{
+		ID:   uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
+		Name: "Test Customer",
+	}
+	if err := customer.save(tmpFolder); err != nil {
+		t.Fatalf("Failed to save customer: %v", err)
+	}
+	task := &Task{
+		ID:         uuid.MustParse("123e4567-e89b-12d3-a456-426614174100"),
+		Customer:   &customer,
+		ExternalID: "PRJ-1",
+		Name:       "Test Task",
+	}
+	ct := &CustomerTasks{Customer: &customer, Tasks: []*Task{task}}
+	if err := ct.save(tmpFolder); err != nil {
+		t.Fatalf("Failed to save tasks: %v", err)
+	}
+
+	s, err := Open(tmpFolder, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	got, err := s.Task(customer.ID, task.ID)
+	if err != nil {
+		t.Fatalf("Store.Task() error = %v", err)
+	}
+	if got.Name != task.Name || got.ExternalID != task.ExternalID {
+		t.Errorf("Store.Task() = %v, want %v", got, task)
+	}
+	if got.Customer == nil || *got.Customer != customer {
+		t.Errorf("Store.Task() customer = %v, want %v", got.Customer, customer)
+	}
+
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	e := NewEntry(got, start)
+	if err := e.save(tmpFolder); err != nil {
+		t.Fatalf("Entry.save() error = %v", err)
+	}
+	entries, err := s.LoadDayEntries(&customer, start)
+	if err != nil {
+		t.Fatalf("Store.LoadDayEntries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != e.ID || entries[0].Task.ID != task.ID {
+		t.Errorf("Store.LoadDayEntries() = %v, want [%v]", entries, e)
+	}
+}
+
+func TestOpen_SeveralRoots(t *testing.T) {
+	customer := Customer{
+		ID:   uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
+		Name: "Test Customer",
+	}
+	var stores []*Store
+	for i := 0; i < 2; i++ {
+		root := t.TempDir()
+		if err := customer.save(root); err != nil {
+			t.Fatalf("Failed to save customer: %v", err)
+		}
+		s, err := Open(root, Options{})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		defer s.Close()
+		stores = append(stores, s)
+	}
+	ct, err := stores[0].LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: &customer, Name: "Only in first"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if _, err := stores[0].Task(customer.ID, task.ID); err != nil {
+		t.Errorf("first store should know the task: %v", err)
+	}
+	if _, err := stores[1].Task(customer.ID, task.ID); err == nil {
+		t.Errorf("second store should not know the task")
+	}
+}
+
+func TestOpen_LegacyEntryLayout(t *testing.T) {
+	root := t.TempDir()
+	customer := Customer{ID: uuid.New(), Name: "Test Customer"}
+	if err := customer.save(root); err != nil {
+		t.Fatalf("Failed to save customer: %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: &customer, Name: "Test Task"}
+	ct := &CustomerTasks{Customer: &customer, Tasks: []*Task{task}}
+	if err := ct.save(root); err != nil {
+		t.Fatalf("Failed to save tasks: %v", err)
+	}
+	// entries used to be stored by day for all customers together
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	e := NewEntry(task, start)
+	legacyDay := filepath.Join(root, "tasks", "2024", "3", "4")
+	if err := os.MkdirAll(legacyDay, os.ModePerm); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(legacyDay, e.ID.String()+".json"), data, 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	s, err := Open(root, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	entries, err := s.LoadDayEntries(&customer, start)
+	if err != nil {
+		t.Fatalf("Store.LoadDayEntries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != e.ID {
+		t.Errorf("Store.LoadDayEntries() = %v, want [%v]", entries, e)
+	}
+	if _, err := os.Stat(filepath.Join(root, "tasks", "2024")); !os.IsNotExist(err) {
+		t.Errorf("legacy year folder still there, Stat() error = %v", err)
+	}
+}
+
+func TestStore_FindTask(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	c := NewCustomer("Acme")
+	if err := s.AddCustomer(c); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := s.LoadTasks(c.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	build := &Task{ID: uuid.New(), Customer: c, Name: "Build", ExternalID: "PRJ-1"}
+	review := &Task{ID: uuid.New(), Customer: c, Name: "PRJ-1"}
+	standups := []*Task{{ID: uuid.New(), Customer: c, Name: "Standup"}, {ID: uuid.New(), Customer: c, Name: "standup"}}
+	for _, task := range append([]*Task{build, review}, standups...) {
+		if err := ct.AddTask(task); err != nil {
+			t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+		}
+	}
+	if err := s.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	if found, err := s.FindCustomer("ACME"); err != nil || found.ID != c.ID {
+		t.Errorf("Store.FindCustomer() = %v, %v, want %s", found, err, c.ID)
+	}
+
+	tests := []struct {
+		ref     string
+		want    uuid.UUID
+		wantErr bool
+	}{
+		{ref: "build", want: build.ID},
+		{ref: review.ID.String(), want: review.ID},
+		{ref: "prj-1", want: build.ID}, // external IDs win over names
+		{ref: "standup", wantErr: true},
+		{ref: "deploy", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := s.FindTask(c.ID, tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Store.FindTask() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.ID != tt.want {
+				t.Errorf("Store.FindTask() = %s, want %s", got.ID, tt.want)
+			}
+		})
+	}
+}
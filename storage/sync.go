@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// remoteName is the git remote the data root syncs with.
+const remoteName = "origin"
+
+// ErrOffline is returned when syncing a store that was not opened online.
+var ErrOffline = errors.New("store is not online")
+
+// setRemote points the remote the data root syncs with to url.
+func (g *gitRepo) setRemote(url string) error {
+	remote, err := g.repo.Remote(remoteName)
+	switch {
+	case err == nil:
+		if urls := remote.Config().URLs; len(urls) == 1 && urls[0] == url {
+			return nil
+		}
+		if err := g.repo.DeleteRemote(remoteName); err != nil {
+			return fmt.Errorf("removing remote %s: %w", remoteName, err)
+		}
+	case !errors.Is(err, git.ErrRemoteNotFound):
+		return fmt.Errorf("reading remote %s: %w", remoteName, err)
+	}
+	if _, err := g.repo.CreateRemote(&config.RemoteConfig{Name: remoteName, URLs: []string{url}}); err != nil {
+		return fmt.Errorf("creating remote %s: %w", remoteName, err)
+	}
+	return nil
+}
+
+// hasRemote tells if the data root has a remote to sync with.
+func (g *gitRepo) hasRemote() bool {
+	_, err := g.repo.Remote(remoteName)
+	return err == nil
+}
+
+// pull fetches the remote and merges it into the current branch, it tells if the data root
+// changed and returns the entry files that were changed both ways, see merge.
+func (g *gitRepo) pull() (bool, []string, error) {
+	err := g.repo.Fetch(&git.FetchOptions{RemoteName: remoteName})
+	switch {
+	case err == nil, errors.Is(err, git.NoErrAlreadyUpToDate):
+	case errors.Is(err, transport.ErrEmptyRemoteRepository):
+		return false, nil, nil
+	default:
+		return false, nil, fmt.Errorf("fetching %s: %w", remoteName, err)
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return false, nil, fmt.Errorf("reading HEAD: %w", err)
+	}
+	remoteRef, err := g.repo.Reference(plumbing.NewRemoteReferenceName(remoteName, head.Name().Short()), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// the branch was never pushed
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("reading remote branch: %w", err)
+	}
+	ours, err := g.repo.CommitObject(head.Hash())
+	if err != nil {
+		return false, nil, fmt.Errorf("reading commit %s: %w", head.Hash(), err)
+	}
+	theirs, err := g.repo.CommitObject(remoteRef.Hash())
+	if err != nil {
+		return false, nil, fmt.Errorf("reading commit %s: %w", remoteRef.Hash(), err)
+	}
+	if ours.Hash == theirs.Hash {
+		return false, nil, nil
+	}
+	if ahead, err := theirs.IsAncestor(ours); err != nil || ahead {
+		return false, nil, err
+	}
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return false, nil, fmt.Errorf("opening worktree: %w", err)
+	}
+	if behind, err := ours.IsAncestor(theirs); err != nil || behind {
+		if err != nil {
+			return false, nil, err
+		}
+		if err := w.Reset(&git.ResetOptions{Commit: theirs.Hash, Mode: git.HardReset}); err != nil {
+			return false, nil, fmt.Errorf("fast forwarding to %s: %w", theirs.Hash, err)
+		}
+		return true, nil, nil
+	}
+	conflicts, entries, err := g.merge(ours, theirs)
+	if err != nil {
+		return false, nil, err
+	}
+	message := "merge " + remoteRef.Name().Short()
+	if len(conflicts) > 0 {
+		message += "\n\n" + strings.Join(conflicts, "\n")
+	}
+	if err := w.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return false, nil, fmt.Errorf("staging merge: %w", err)
+	}
+	_, err = w.Commit(message, &git.CommitOptions{Author: signature(), Parents: []plumbing.Hash{ours.Hash, theirs.Hash}})
+	if err != nil {
+		return false, nil, fmt.Errorf("committing merge: %w", err)
+	}
+	return true, entries, nil
+}
+
+// merge brings the changes made in theirs since the common ancestor into the worktree, which
+// holds ours, files changed on both sides are merged by MergeDataFile. It returns what was
+// changed both ways and how it was dealt with, along with the entry files merged that way,
+// their ledger records no longer match them.
+func (g *gitRepo) merge(ours, theirs *object.Commit) ([]string, []string, error) {
+	var base *object.Commit
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("finding merge base: %w", err)
+	}
+	if len(bases) > 0 {
+		base = bases[0]
+	}
+	var hashes [3]map[string]plumbing.Hash
+	for i, c := range []*object.Commit{base, ours, theirs} {
+		hashes[i] = map[string]plumbing.Hash{}
+		if c == nil {
+			// unrelated histories, everything was added on both sides
+			continue
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading tree of %s: %w", c.Hash, err)
+		}
+		err = tree.Files().ForEach(func(f *object.File) error {
+			hashes[i][f.Name] = f.Hash
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing files of %s: %w", c.Hash, err)
+		}
+	}
+	names := map[string]bool{}
+	for _, files := range hashes {
+		for name := range files {
+			names[name] = true
+		}
+	}
+	var conflicts, entries []string
+	for name := range names {
+		b, o, t := hashes[0][name], hashes[1][name], hashes[2][name]
+		path := filepath.Join(g.root, filepath.FromSlash(name))
+		switch {
+		case o == t, b == t:
+			// nothing changed on their side
+		case b == o && t.IsZero():
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, nil, fmt.Errorf("removing %s: %w", path, err)
+			}
+			removeEmptyDirs(g.root, filepath.Dir(path))
+		case b == o, o.IsZero():
+			if o.IsZero() && !b.IsZero() {
+				conflicts = append(conflicts, name+": deleted here but edited there, kept the edit")
+			}
+			content, err := g.blob(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := writeDataFile(path, content); err != nil {
+				return nil, nil, err
+			}
+		case t.IsZero():
+			conflicts = append(conflicts, name+": deleted there but edited here, kept the edit")
+		default:
+			var baseContent []byte
+			if !b.IsZero() {
+				if baseContent, err = g.blob(b); err != nil {
+					return nil, nil, err
+				}
+			}
+			ourContent, err := g.blob(o)
+			if err != nil {
+				return nil, nil, err
+			}
+			theirContent, err := g.blob(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			merged, err := MergeDataFile(name, baseContent, ourContent, theirContent)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := writeDataFile(path, merged.Content); err != nil {
+				return nil, nil, err
+			}
+			conflicts = append(conflicts, merged.Resolved...)
+			conflicts = append(conflicts, merged.Conflicts...)
+			if isEntryFile(name) {
+				entries = append(entries, name)
+			}
+		}
+	}
+	return conflicts, entries, nil
+}
+
+// blob returns the content of a file stored in the repository.
+func (g *gitRepo) blob(hash plumbing.Hash) ([]byte, error) {
+	blob, err := g.repo.BlobObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", hash, err)
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", hash, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// writeDataFile atomically writes content to path creating its folder if needed.
+func writeDataFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("could not create directory %s: %w", filepath.Dir(path), err)
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+}
+
+// push sends the current branch to the remote.
+func (g *gitRepo) push() error {
+	head, err := g.repo.Head()
+	if err != nil {
+		return fmt.Errorf("reading HEAD: %w", err)
+	}
+	refSpec := config.RefSpec(head.Name().String() + ":" + head.Name().String())
+	err = g.repo.Push(&git.PushOptions{RemoteName: remoteName, RefSpecs: []config.RefSpec{refSpec}})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("pushing to %s: %w", remoteName, err)
+	}
+	return nil
+}
+
+// Sync merges the changes pushed by other machines, entry by entry when both edited the same
+// ones, and pushes the local changes. Online stores sync on open and after every change.
+func (s *Store) Sync() error {
+	if !s.online {
+		return ErrOffline
+	}
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.sync()
+}
+
+// sync is Sync with the root lock held exclusively.
+func (s *Store) sync() error {
+	changed, merged, err := s.git.pull()
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := s.settle(merged); err != nil {
+			return err
+		}
+		if err := s.reload(); err != nil {
+			return err
+		}
+	}
+	return s.git.push()
+}
+
+// settle puts the data root back in order after a pull brought changes in: the entries merged
+// both ways are chained again and the timers finished on either side are no longer running.
+// The root lock must be held exclusively.
+func (s *Store) settle(merged []string) error {
+	if err := s.chainMerged(merged); err != nil {
+		return err
+	}
+	if err := s.pruneActive(); err != nil {
+		return err
+	}
+	return s.git.commit("settle merge")
+}
+
+// chainMerged records in the ledgers the entry files a pull merged both ways, so the merged
+// versions verify against the chain like any other save. Entries merged within a sealed period
+// are left out and reported, verifying their chain flags them. The root lock must be held
+// exclusively.
+func (s *Store) chainMerged(names []string) error {
+	for _, name := range names {
+		path := filepath.Join(s.root, filepath.FromSlash(name))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading merged entry: %w", err)
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("decode merged entry %s: %w", path, err)
+		}
+		l, err := loadLedger(s.root, e.reference().CustomerID)
+		if err != nil {
+			return err
+		}
+		hash, err := hashEntry(&e)
+		if err != nil {
+			return err
+		}
+		if r, ok := l.state()[e.ID]; ok && r.Op == ledgerSave && r.EntryHash == hash {
+			continue
+		}
+		record, err := s.chain(ledgerSave, &e)
+		if errors.Is(err, ErrSealed) {
+			s.warn(fmt.Errorf("merged entry %s is not chained: %w", e.ID, err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(&e); err != nil {
+			return fmt.Errorf("could not encode entry: %w", err)
+		}
+		if err := writeDataFile(path, append(data, '\n')); err != nil {
+			return err
+		}
+		if err := record(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/google/uuid"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_Sync(t *testing.T) {
+	remote := t.TempDir()
+	if _, err := git.PlainInit(remote, true); err != nil {
+		t.Fatalf("PlainInit() error = %v", err)
+	}
+	open := func() *Store {
+		s, err := Open(t.TempDir(), Options{Online: true, Remote: remote, Location: time.UTC})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+	day := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+
+	// the first machine sets everything up
+	laptop := open()
+	customer := NewCustomer("Test Customer")
+	if err := laptop.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := laptop.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: customer, Name: "Design"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := laptop.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	shared := NewEntry(task, day.Add(9*time.Hour))
+	if err := laptop.SaveEntry(shared); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+
+	// the second one pulls it on open
+	desktop := open()
+	entries, err := desktop.LoadEntries(EntryFilter{})
+	if err != nil {
+		t.Fatalf("Store.LoadEntries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != shared.ID {
+		t.Fatalf("Store.LoadEntries() after open = %v, want the entry of the other machine", entries)
+	}
+
+	// both edit the same day, the shared entry in different ways
+	if err := laptop.FinishEntryAt(shared, day.Add(10*time.Hour)); err != nil {
+		t.Fatalf("Store.FinishEntryAt() error = %v", err)
+	}
+	laptopOnly := NewEntry(task, day.Add(11*time.Hour))
+	if err := laptop.SaveEntry(laptopOnly); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	entries[0].Comment = "wireframes"
+	if err := desktop.SaveEntry(entries[0]); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	desktopOnly := NewEntry(task, day.Add(14*time.Hour))
+	if err := desktop.SaveEntry(desktopOnly); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+
+	if err := laptop.Sync(); err != nil {
+		t.Fatalf("Store.Sync() error = %v", err)
+	}
+	for name, s := range map[string]*Store{"laptop": laptop, "desktop": desktop} {
+		got, err := s.LoadEntries(EntryFilter{})
+		if err != nil {
+			t.Fatalf("%s Store.LoadEntries() error = %v", name, err)
+		}
+		assertEntries(t, got[:1], []*Entry{{ID: shared.ID, Comment: "wireframes", StartTS: shared.StartTS, EndTs: shared.EndTs, Task: task}})
+		if len(got) != 3 || got[1].ID != laptopOnly.ID || got[2].ID != desktopOnly.ID {
+			t.Errorf("%s Store.LoadEntries() = %v, want the entries of both machines", name, got)
+		}
+	}
+}
+
+func TestStore_SyncChain(t *testing.T) {
+	remote := t.TempDir()
+	if _, err := git.PlainInit(remote, true); err != nil {
+		t.Fatalf("PlainInit() error = %v", err)
+	}
+	laptop := openOnline(t, remote)
+	customer := NewCustomer("Test Customer")
+	if err := laptop.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := laptop.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: customer, Name: "Design"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := laptop.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	day := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	shared := NewEntry(task, day.Add(9*time.Hour))
+	if err := laptop.SaveEntry(shared); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	desktop := openOnline(t, remote)
+	entries, err := desktop.LoadEntries(EntryFilter{})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Store.LoadEntries() = %v, %v, want the entry of the other machine", entries, err)
+	}
+
+	// both chains grow apart, with an entry each and the shared one edited both ways
+	if err := laptop.FinishEntryAt(shared, day.Add(10*time.Hour)); err != nil {
+		t.Fatalf("Store.FinishEntryAt() error = %v", err)
+	}
+	if err := laptop.SaveEntry(NewEntry(task, day.Add(11*time.Hour))); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	entries[0].Comment = "wireframes"
+	if err := desktop.SaveEntry(entries[0]); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	if err := desktop.SaveEntry(NewEntry(task, day.Add(14*time.Hour))); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	if err := laptop.Sync(); err != nil {
+		t.Fatalf("Store.Sync() error = %v", err)
+	}
+
+	for name, s := range map[string]*Store{"laptop": laptop, "desktop": desktop} {
+		problems, err := s.VerifyChain(customer.ID)
+		if err != nil {
+			t.Fatalf("%s Store.VerifyChain() error = %v", name, err)
+		}
+		if len(problems) != 0 {
+			t.Errorf("%s Store.VerifyChain() = %v, want no problems", name, problems)
+		}
+		got, err := s.LoadEntries(EntryFilter{})
+		if err != nil {
+			t.Fatalf("%s Store.LoadEntries() error = %v", name, err)
+		}
+		if len(got) != 3 {
+			t.Errorf("%s Store.LoadEntries() = %v, want the entries of both machines", name, got)
+		}
+	}
+}
+
+func TestStore_SyncTimers(t *testing.T) {
+	remote := t.TempDir()
+	if _, err := git.PlainInit(remote, true); err != nil {
+		t.Fatalf("PlainInit() error = %v", err)
+	}
+	laptop := openOnline(t, remote)
+	customer := NewCustomer("Test Customer")
+	if err := laptop.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := laptop.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: customer, Name: "Design"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := laptop.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	desktop := openOnline(t, remote)
+
+	// a timer starts on each machine before they sync
+	now := time.Now().UTC().Truncate(time.Second)
+	onLaptop, onDesktop := NewEntry(task, now.Add(-2*time.Hour)), NewEntry(task, now.Add(-time.Hour))
+	if _, err := laptop.Start(onLaptop); err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	if _, err := desktop.Start(onDesktop); err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	if err := laptop.Sync(); err != nil {
+		t.Fatalf("Store.Sync() error = %v", err)
+	}
+	for name, s := range map[string]*Store{"laptop": laptop, "desktop": desktop} {
+		running, err := s.Status()
+		if err != nil {
+			t.Fatalf("%s Store.Status() error = %v", name, err)
+		}
+		if len(running) != 2 || running[0].ID != onLaptop.ID || running[1].ID != onDesktop.ID {
+			t.Errorf("%s Store.Status() = %v, want the timers of both machines", name, running)
+		}
+	}
+
+	// starting another one finishes both
+	finished, err := laptop.Start(NewEntry(task, now))
+	if err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	if len(finished) != 2 {
+		t.Errorf("Store.Start() finished %v, want the timers of both machines", finished)
+	}
+	if err := desktop.Sync(); err != nil {
+		t.Fatalf("Store.Sync() error = %v", err)
+	}
+	for name, s := range map[string]*Store{"laptop": laptop, "desktop": desktop} {
+		if running, err := s.Status(); err != nil || len(running) != 1 {
+			t.Errorf("%s Store.Status() = %v, %v, want the last timer only", name, running, err)
+		}
+		if problems, err := s.VerifyChain(customer.ID); err != nil || len(problems) != 0 {
+			t.Errorf("%s Store.VerifyChain() = %v, %v, want no problems", name, problems, err)
+		}
+	}
+}
+
+// openOnline opens a new data root syncing with remote.
+func openOnline(t *testing.T, remote string) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir(), Options{Online: true, Remote: remote, Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_SyncOffline(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{VersionControl: true})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	if err := s.Sync(); !errors.Is(err, ErrOffline) {
+		t.Errorf("Store.Sync() error = %v, want %v", err, ErrOffline)
+	}
+}
+
+func TestStore_SyncUnreachable(t *testing.T) {
+	var warnings []error
+	remote := filepath.Join(t.TempDir(), "missing")
+	s, err := Open(t.TempDir(), Options{Online: true, Remote: remote, Warn: func(err error) {
+		warnings = append(warnings, err)
+	}})
+	if err != nil {
+		t.Fatalf("Open() error = %v, want to work offline", err)
+	}
+	defer s.Close()
+	if len(warnings) != 1 {
+		t.Fatalf("Open() warnings = %v, want the failed pull", warnings)
+	}
+	if err := s.AddCustomer(NewCustomer("Acme")); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v, want to work offline", err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("Store.AddCustomer() warnings = %v, want the failed sync", warnings)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -14,15 +15,23 @@ type Task struct {
 	Customer   *Customer `json:"customer"`
 	ExternalID string    `json:"external_id"` // think jira PRJ-#### or similar
 	Name       string    `json:"name"`
+	Archived   bool      `json:"archived,omitempty"` // hidden from listings and searches, its entries are kept
+
+	customerID uuid.UUID // customer reference as decoded until Store.setTasks binds the cached customer
 }
 
+// plainTask has the fields of Task but none of its methods, so it can be embedded by the
+// json methods without recursing into them.
+type plainTask Task
+
 // MarshalJSON method for Task to be able to serialize customer
 func (t *Task) MarshalJSON() ([]byte, error) {
 	alias := &struct {
 		CustomerID uuid.UUID `json:"customer"`
-		*Task
+		*plainTask
 	}{
-		Task: t,
+		CustomerID: t.customerID,
+		plainTask:  (*plainTask)(t),
 	}
 
 	if t.Customer != nil {
@@ -32,13 +41,14 @@ func (t *Task) MarshalJSON() ([]byte, error) {
 	return json.MarshalIndent(alias, "", "  ")
 }
 
-// UnmarshalJSON method for Task to be able to de-serialize Customer
+// UnmarshalJSON method for Task to be able to de-serialize Customer, the customer reference
+// is only recorded, it gets resolved by LoadTasks or the Store.
 func (t *Task) UnmarshalJSON(data []byte) error {
 	aux := &struct {
 		CustomerID uuid.UUID `json:"customer"`
-		*Task
+		*plainTask
 	}{
-		Task: t,
+		plainTask: (*plainTask)(t),
 	}
 
 	if err := json.Unmarshal(data, aux); err != nil {
@@ -48,11 +58,8 @@ func (t *Task) UnmarshalJSON(data []byte) error {
 	if aux.CustomerID == uuid.Nil {
 		return fmt.Errorf("invalid task customer ID")
 	}
-	customer, ok := customerFromID[aux.CustomerID]
-	if !ok {
-		return fmt.Errorf("customer ID $%s of task is non existent: %w", aux.CustomerID.String(), ErrNotFound)
-	}
-	t.Customer = &customer
+	t.customerID = aux.CustomerID
+	t.Customer = nil
 
 	return nil
 }
@@ -69,6 +76,11 @@ func EntriesSavePath(root string, c *Customer) string {
 
 // EnsureTaskEntriesFolder creates the entries folder if it does not exist and returns it.
 func (t *Task) EnsureTaskEntriesFolder(root string) (string, error) {
+	unlock, err := lockRoot(root, true, DefaultLockTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
 	entriesPath := t.EntriesSavePath(root)
 	if err := os.MkdirAll(entriesPath, os.ModePerm); err != nil {
 		return "", fmt.Errorf("failed to create task entries folder %s: %w", entriesPath, err)
@@ -76,44 +88,54 @@ func (t *Task) EnsureTaskEntriesFolder(root string) (string, error) {
 	return entriesPath, nil
 }
 
+// CustomerTasks holds all the tasks of a customer, unlike the Store it is not safe for concurrent use.
 type CustomerTasks struct {
 	Customer *Customer `json:"customer"`
 	Tasks    []*Task   `json:"tasks"`
+
+	store *Store // set when loaded through a Store, used to keep its caches current
 }
 
-// AddTask adds a task to the customer tasks, it also indexes it for search.
+// AddTask adds a task to the customer tasks, it also indexes it for search when the tasks
+// were loaded through a Store.
 func (c *CustomerTasks) AddTask(t *Task) error {
 	c.Tasks = append(c.Tasks, t)
-	err := taskIndex[t.Customer.ID].Index(t.Name, t)
-	if err != nil {
-		return fmt.Errorf("indexing task %s for customer %s: %w", t.Name, c.Customer.Name, err)
+	if c.store == nil {
+		return nil
 	}
-	return nil
+	return c.store.cacheTask(c.Customer, t)
 }
 
-// Save will persist the customer tasks
-func (c *CustomerTasks) Save(root string) error {
+// save will persist the customer tasks
+func (c *CustomerTasks) save(root string) error {
+	unlock, err := lockRoot(root, true, DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	customerSavePath, err := c.Customer.EnsureFolder(root)
 	if err != nil {
 		return fmt.Errorf("ensuring customer folder exist: %w", err)
 	}
 	tasksSavePath := filepath.Join(customerSavePath, "tasks.json")
-	f, err := os.Create(tasksSavePath)
-	if err != nil {
-		return fmt.Errorf("create or truncate tasks file: %w", err)
-	}
-	defer f.Close()
-	m := json.NewEncoder(f)
-	m.SetIndent("", "  ")
-	err = m.Encode(c.Tasks)
-	if err != nil {
-		return fmt.Errorf("encode tasks file: %w", err)
-	}
-	return nil
+	return writeFileAtomic(tasksSavePath, func(w io.Writer) error {
+		m := json.NewEncoder(w)
+		m.SetIndent("", "  ")
+		err := m.Encode(c.Tasks)
+		if err != nil {
+			return fmt.Errorf("encode tasks file: %w", err)
+		}
+		return nil
+	})
 }
 
 // LoadTasks Reads tasks for a given customer.
 func LoadTasks(root string, c *Customer) (*CustomerTasks, error) {
+	unlock, err := lockRoot(root, false, DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	if c == nil {
 		return nil, fmt.Errorf("LoadTasks: customer must not be nil")
 	}
@@ -134,9 +156,15 @@ func LoadTasks(root string, c *Customer) (*CustomerTasks, error) {
 	ct := &CustomerTasks{
 		Customer: c,
 	}
-	err = m.Decode(ct)
+	err = m.Decode(&ct.Tasks)
 	if err != nil {
 		return nil, fmt.Errorf("decode tasks file: %w", err)
 	}
+	for _, t := range ct.Tasks {
+		if t.customerID != c.ID {
+			return nil, fmt.Errorf("task %s belongs to customer %s not %s", t.ID, t.customerID, c.ID)
+		}
+		t.Customer = c
+	}
 	return ct, nil
 }
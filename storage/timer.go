@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrTimerRunning is returned when starting a timer before the one running started, it cannot
+// be finished in its place.
+var ErrTimerRunning = errors.New("another timer is running")
+
+// activeEntry points to an entry not finished yet, enough to load it back from any backend.
+type activeEntry struct {
+	EntryID    uuid.UUID `json:"entry_id"`
+	CustomerID uuid.UUID `json:"customer_id"`
+	Start      time.Time `json:"start"`
+}
+
+// activePath returns the file recording the running timers of a data root, it is versioned
+// along with the data so every machine sees the same timers.
+func activePath(root string) string {
+	return filepath.Join(root, "active.json")
+}
+
+// loadActive returns the running timers, the root lock must be held. Data roots from before
+// the record existed get theirs from the last entry of every customer.
+func (s *Store) loadActive() ([]activeEntry, error) {
+	data, err := os.ReadFile(activePath(s.root))
+	if os.IsNotExist(err) {
+		return s.scanActive()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading running timers: %w", err)
+	}
+	var active []activeEntry
+	if err := json.Unmarshal(data, &active); err != nil {
+		return nil, fmt.Errorf("decoding running timers: %w", err)
+	}
+	return active, nil
+}
+
+// scanActive finds the running timers among the last entries of every customer.
+func (s *Store) scanActive() ([]activeEntry, error) {
+	var active []activeEntry
+	for _, id := range s.customerIDs() {
+		e, err := s.backend.LastEntry(id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading last entry of customer %s: %w", id, err)
+		}
+		if e.EndTs == nil {
+			active = append(active, activeEntry{EntryID: e.ID, CustomerID: id, Start: e.StartTS})
+		}
+	}
+	return active, nil
+}
+
+// saveActive replaces the running timers, the root lock must be held exclusively.
+func (s *Store) saveActive(active []activeEntry) error {
+	err := writeFileAtomic(activePath(s.root), func(w io.Writer) error {
+		return encodeActive(w, active)
+	})
+	if err != nil {
+		return fmt.Errorf("saving running timers: %w", err)
+	}
+	return nil
+}
+
+// encodeActive writes the running timers sorted by start.
+func encodeActive(w io.Writer, active []activeEntry) error {
+	if active == nil {
+		active = []activeEntry{}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Start.Before(active[j].Start)
+	})
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(active)
+}
+
+// updateActive records the entries just saved, finished or deleted through the store as running
+// or not, the root lock must be held exclusively.
+func (s *Store) updateActive(deleted bool, entries ...*Entry) error {
+	active, err := s.loadActive()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, e := range entries {
+		kept := active[:0]
+		for _, a := range active {
+			if a.EntryID != e.ID {
+				kept = append(kept, a)
+			}
+		}
+		changed = changed || len(kept) != len(active)
+		active = kept
+		if !deleted && e.EndTs == nil {
+			active = append(active, activeEntry{EntryID: e.ID, CustomerID: e.reference().CustomerID, Start: e.StartTS})
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveActive(active)
+}
+
+// pruneActive forgets the running timers whose entries were finished or removed, on another
+// machine for instance, the root lock must be held exclusively.
+func (s *Store) pruneActive() error {
+	active, err := s.loadActive()
+	if err != nil {
+		return err
+	}
+	kept := active[:0]
+	for _, a := range active {
+		start := a.Start.Truncate(time.Second)
+		entries, err := s.backend.LoadEntries(a.CustomerID, start, start.Add(time.Second))
+		if err != nil {
+			return fmt.Errorf("loading entry %s: %w", a.EntryID, err)
+		}
+		for _, e := range entries {
+			if e.ID == a.EntryID && e.EndTs == nil {
+				kept = append(kept, a)
+			}
+		}
+	}
+	if len(kept) == len(active) {
+		return nil
+	}
+	return s.saveActive(kept)
+}
+
+// mergeActive merges three versions of the running timers by entry ID, a timer started on
+// either side is kept unless it was running before and one side finished it.
+func mergeActive(base, ours, theirs []byte, r *MergeResult) ([]byte, error) {
+	var versions [3]map[uuid.UUID]activeEntry
+	for i, data := range [][]byte{base, ours, theirs} {
+		var active []activeEntry
+		if data != nil {
+			if err := json.Unmarshal(data, &active); err != nil {
+				return nil, fmt.Errorf("decoding running timers: %w", err)
+			}
+		}
+		versions[i] = make(map[uuid.UUID]activeEntry, len(active))
+		for _, a := range active {
+			versions[i][a.EntryID] = a
+		}
+	}
+	b, o, t := versions[0], versions[1], versions[2]
+	var merged []activeEntry
+	startedHere, startedThere := false, false
+	for id, a := range o {
+		_, running := b[id]
+		_, there := t[id]
+		switch {
+		case !running:
+			startedHere = true
+			merged = append(merged, a)
+		case there:
+			merged = append(merged, a)
+		}
+	}
+	for id, a := range t {
+		if _, ok := b[id]; !ok {
+			if _, ok := o[id]; !ok {
+				startedThere = true
+				merged = append(merged, a)
+			}
+		}
+	}
+	if startedHere && startedThere {
+		r.Resolved = append(r.Resolved, "timers started on both sides, kept them all")
+	}
+	var buf bytes.Buffer
+	if err := encodeActive(&buf, merged); err != nil {
+		return nil, fmt.Errorf("encoding merged running timers: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Start starts the timer of a new entry, unless parallel timers are enabled the running ones are
+// finished when it starts and returned. Only one timer runs at a time this way, whatever customer
+// it is for, SaveEntry records entries as running without finishing the others. Everything is
+// saved at once, so on failure the running entries are left as they were.
+func (s *Store) Start(e *Entry) ([]*Entry, error) {
+	if e.EndTs != nil {
+		return nil, fmt.Errorf("entry %s is already finished", e.ID)
+	}
+	unlock, err := s.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var finished, entries []*Entry
+	if !s.parallelTimers {
+		running, err := s.Status()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range running {
+			if r.ID == e.ID {
+				continue
+			}
+			if e.StartTS.Before(r.StartTS) {
+				return nil, fmt.Errorf("entry %s starts before the running %s: %w", e.ID, r.ID, ErrTimerRunning)
+			}
+			fragments, err := splitEntry(r, e.StartTS, s.location)
+			if err != nil {
+				return nil, err
+			}
+			finished = append(finished, r)
+			entries = append(entries, fragments...)
+		}
+	}
+	entries = append(entries, e)
+	record, err := s.chain(ledgerSave, entries...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.SaveEntries(entries...); err != nil {
+		return nil, fmt.Errorf("saving entry %s: %w", e.ID, err)
+	}
+	if err := record(); err != nil {
+		return nil, err
+	}
+	if err := s.updateActive(false, entries...); err != nil {
+		return nil, err
+	}
+	if err := s.updateComments(false, entries...); err != nil {
+		return nil, err
+	}
+	if err := s.commit("start entry %s", s.describeEntry(e)); err != nil {
+		return nil, err
+	}
+	return finished, nil
+}
+
+// Status returns the running entries sorted by start, whatever day they started. There is
+// at most one unless parallel timers are enabled.
+func (s *Store) Status() ([]*Entry, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	active, err := s.loadActive()
+	if err != nil {
+		return nil, err
+	}
+	running := make([]*Entry, 0, len(active))
+	for _, a := range active {
+		e, err := s.loadEntry(a.CustomerID, a.EntryID, a.Start)
+		if errors.Is(err, ErrNotFound) {
+			// removed or moved behind the store, see Watch
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.EndTs == nil {
+			running = append(running, e)
+		}
+	}
+	sort.Sort(Entries(running))
+	return running, nil
+}
+
+// ParallelTimers tells if several timers can run at once, see Options.ParallelTimers.
+func (s *Store) ParallelTimers() bool {
+	return s.parallelTimers
+}
+
+// forgetActive drops the running timers of a customer being deleted, the root lock must be
+// held exclusively.
+func (s *Store) forgetActive(customerID uuid.UUID) error {
+	active, err := s.loadActive()
+	if err != nil {
+		return err
+	}
+	kept := active[:0]
+	for _, a := range active {
+		if a.CustomerID != customerID {
+			kept = append(kept, a)
+		}
+	}
+	if len(kept) == len(active) {
+		return nil
+	}
+	return s.saveActive(kept)
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTimerStore opens a store with a task for each of two customers.
+func newTimerStore(t *testing.T, opts Options) (*Store, []*Task) {
+	t.Helper()
+	opts.Location = time.UTC
+	s, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	var tasks []*Task
+	for _, name := range []string{"First Customer", "Second Customer"} {
+		customer := NewCustomer(name)
+		if err := s.AddCustomer(customer); err != nil {
+			t.Fatalf("Store.AddCustomer() error = %v", err)
+		}
+		ct, err := s.LoadTasks(customer.ID)
+		if err != nil {
+			t.Fatalf("Store.LoadTasks() error = %v", err)
+		}
+		task := &Task{ID: uuid.New(), Customer: customer, Name: "Task"}
+		if err := ct.AddTask(task); err != nil {
+			t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+		}
+		if err := s.SaveTasks(ct); err != nil {
+			t.Fatalf("Store.SaveTasks() error = %v", err)
+		}
+		tasks = append(tasks, task)
+	}
+	return s, tasks
+}
+
+// runningIDs returns the IDs of the entries reported running by Store.Status.
+func runningIDs(t *testing.T, s *Store) []uuid.UUID {
+	t.Helper()
+	running, err := s.Status()
+	if err != nil {
+		t.Fatalf("Store.Status() error = %v", err)
+	}
+	var ids []uuid.UUID
+	for _, e := range running {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestStore_Start(t *testing.T) {
+	// started days ago, Status is not limited to today
+	first := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		opts         Options
+		secondStart  time.Time
+		wantErr      error
+		wantFinished bool
+		wantRunning  func(a, b *Entry) []uuid.UUID
+	}{
+		{
+			name:         "finishes the running timer",
+			secondStart:  first.Add(2 * time.Hour),
+			wantFinished: true,
+			wantRunning:  func(a, b *Entry) []uuid.UUID { return []uuid.UUID{b.ID} },
+		},
+		{
+			name:        "parallel timers",
+			opts:        Options{ParallelTimers: true},
+			secondStart: first.Add(2 * time.Hour),
+			wantRunning: func(a, b *Entry) []uuid.UUID { return []uuid.UUID{a.ID, b.ID} },
+		},
+		{
+			name:        "before the running timer",
+			secondStart: first.Add(-time.Hour),
+			wantErr:     ErrTimerRunning,
+			wantRunning: func(a, b *Entry) []uuid.UUID { return []uuid.UUID{a.ID} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tasks := newTimerStore(t, tt.opts)
+			a := NewEntry(tasks[0], first)
+			if finished, err := s.Start(a); err != nil || len(finished) != 0 {
+				t.Fatalf("Store.Start() = %v, %v, want nothing finished", finished, err)
+			}
+			b := NewEntry(tasks[1], tt.secondStart)
+			finished, err := s.Start(b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Store.Start() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantFinished {
+				if len(finished) != 1 || finished[0].ID != a.ID || !finished[0].EndTs.Equal(tt.secondStart) {
+					t.Errorf("Store.Start() finished %v, want %s at %s", finished, a.ID, tt.secondStart)
+				}
+			} else if len(finished) != 0 {
+				t.Errorf("Store.Start() finished %v, want nothing", finished)
+			}
+			got, want := runningIDs(t, s), tt.wantRunning(a, b)
+			if len(got) != len(want) {
+				t.Fatalf("Store.Status() = %v, want %v", got, want)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("Store.Status()[%d] = %s, want %s", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStore_StartAtOnce(t *testing.T) {
+	s, tasks := newTimerStore(t, Options{VersionControl: true})
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	a := NewEntry(tasks[0], start)
+	if _, err := s.Start(a); err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	if _, err := s.Seal(tasks[1].Customer.ID, start.Truncate(24*time.Hour), start.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("Store.Seal() error = %v", err)
+	}
+	revisions := func() int {
+		t.Helper()
+		history, err := s.History(0)
+		if err != nil {
+			t.Fatalf("Store.History() error = %v", err)
+		}
+		return len(history)
+	}
+	before := revisions()
+
+	// switching into a sealed day fails without stopping the running timer
+	if _, err := s.Start(NewEntry(tasks[1], start.Add(time.Hour))); !errors.Is(err, ErrSealed) {
+		t.Fatalf("Store.Start() error = %v, want ErrSealed", err)
+	}
+	if got := runningIDs(t, s); len(got) != 1 || got[0] != a.ID {
+		t.Errorf("Store.Status() after failing = %v, want %s", got, a.ID)
+	}
+	if got := revisions(); got != before {
+		t.Errorf("Store.Start() failing added %d revisions, want none", got-before)
+	}
+
+	// switching across midnight finishes a in two fragments within a single revision
+	b := NewEntry(tasks[1], start.AddDate(0, 0, 1).Add(time.Hour))
+	finished, err := s.Start(b)
+	if err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	if len(finished) != 1 || finished[0].ID != a.ID || finished[0].EndTs == nil {
+		t.Errorf("Store.Start() finished %v, want %s", finished, a.ID)
+	}
+	if got := runningIDs(t, s); len(got) != 1 || got[0] != b.ID {
+		t.Errorf("Store.Status() after switching = %v, want %s", got, b.ID)
+	}
+	if got := revisions(); got != before+1 {
+		t.Errorf("Store.Start() added %d revisions, want 1", got-before)
+	}
+	for _, task := range tasks {
+		if problems, err := s.VerifyChain(task.Customer.ID); err != nil || len(problems) != 0 {
+			t.Errorf("Store.VerifyChain() = %v, %v, want no problems", problems, err)
+		}
+	}
+}
+
+func TestStore_Status(t *testing.T) {
+	s, tasks := newTimerStore(t, Options{})
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	e := NewEntry(tasks[0], start)
+	if _, err := s.Start(e); err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	current, err := s.LoadCurrentEntry(tasks[0].Customer)
+	if err != nil || current.ID != e.ID {
+		t.Fatalf("Store.LoadCurrentEntry() = %v, %v, want %s", current, err, e.ID)
+	}
+	if _, err := s.LoadCurrentEntry(tasks[1].Customer); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Store.LoadCurrentEntry() of another customer error = %v, want %v", err, ErrNotFound)
+	}
+
+	// roots from before the record find their timers all the same
+	if err := os.Remove(activePath(s.root)); err != nil {
+		t.Fatal(err)
+	}
+	if got := runningIDs(t, s); len(got) != 1 || got[0] != e.ID {
+		t.Errorf("Store.Status() without record = %v, want %s", got, e.ID)
+	}
+
+	// finished across midnight, the record goes along with every fragment
+	if err := s.FinishEntryAt(e, start.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("Store.FinishEntryAt() error = %v", err)
+	}
+	if got := runningIDs(t, s); len(got) != 0 {
+		t.Errorf("Store.Status() after finishing = %v, want nothing", got)
+	}
+
+	d := NewEntry(tasks[1], start.AddDate(0, 0, 2))
+	if _, err := s.Start(d); err != nil {
+		t.Fatalf("Store.Start() error = %v", err)
+	}
+	if err := s.DeleteEntry(d); err != nil {
+		t.Fatalf("Store.DeleteEntry() error = %v", err)
+	}
+	if got := runningIDs(t, s); len(got) != 0 {
+		t.Errorf("Store.Status() after deleting = %v, want nothing", got)
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ErrBadSignature is returned when a signed timesheet does not match its signature.
+var ErrBadSignature = errors.New("signature does not match the timesheet")
+
+// Timesheet is the export of the finished entries of a customer started within [From, To). It
+// names the key that signed it and the head of the customer chain when it was exported.
+type Timesheet struct {
+	CustomerID  uuid.UUID         `json:"customer_id"`
+	Customer    string            `json:"customer"`
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Entries     []TimesheetEntry  `json:"entries"`
+	Total       string            `json:"total"`
+	TotalNet    string            `json:"total_net"` // Total without the breaks
+	ChainSeq    uint64            `json:"chain_seq"`
+	ChainHead   string            `json:"chain_head"`
+	Exported    time.Time         `json:"exported"`
+	PublicKey   ed25519.PublicKey `json:"public_key"`
+	Fingerprint string            `json:"fingerprint"`
+}
+
+// TimesheetEntry is an entry as exported in a timesheet.
+type TimesheetEntry struct {
+	ID       uuid.UUID `json:"id"`
+	Task     string    `json:"task"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration string    `json:"duration"`
+	Net      string    `json:"net"` // Duration without the breaks
+	Breaks   []Break   `json:"breaks,omitempty"`
+	Comment  string    `json:"comment,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+}
+
+// signedTimesheet is the exported file, the signature covers the compact encoding of the timesheet.
+type signedTimesheet struct {
+	Timesheet json.RawMessage `json:"timesheet"`
+	Signature []byte          `json:"signature"`
+}
+
+// ExportTimesheet returns the timesheet of a customer for the entries started within [from, to)
+// signed with the key of the data root, see VerifyTimesheet. Running entries are left out.
+func (s *Store) ExportTimesheet(customerID uuid.UUID, from, to time.Time) ([]byte, error) {
+	c, err := s.Customer(customerID)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	private, err := s.signingKey()
+	if err != nil {
+		return nil, err
+	}
+	l, err := loadLedger(s.root, customerID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.LoadEntries(EntryFilter{CustomerID: customerID, From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+	public := private.Public().(ed25519.PublicKey)
+	ts := Timesheet{
+		CustomerID:  c.ID,
+		Customer:    c.Name,
+		From:        from,
+		To:          to,
+		Entries:     []TimesheetEntry{},
+		Exported:    time.Now().UTC(),
+		PublicKey:   public,
+		Fingerprint: Fingerprint(public),
+	}
+	ts.ChainSeq, ts.ChainHead = l.head()
+	var total, totalNet time.Duration
+	for _, e := range entries {
+		if e.EndTs == nil {
+			continue
+		}
+		duration, net := e.Duration(*e.EndTs), e.NetDuration(*e.EndTs)
+		total += duration
+		totalNet += net
+		ts.Entries = append(ts.Entries, TimesheetEntry{
+			ID:       e.ID,
+			Task:     e.Task.Name,
+			Start:    e.StartTS,
+			End:      *e.EndTs,
+			Duration: duration.String(),
+			Net:      net.String(),
+			Breaks:   e.Breaks,
+			Comment:  e.Comment,
+			Tags:     e.Tags,
+		})
+	}
+	ts.Total = total.String()
+	ts.TotalNet = totalNet.String()
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return nil, fmt.Errorf("encoding timesheet: %w", err)
+	}
+	signed, err := json.MarshalIndent(signedTimesheet{Timesheet: data, Signature: ed25519.Sign(private, data)}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding signed timesheet: %w", err)
+	}
+	return append(signed, '\n'), nil
+}
+
+// VerifyTimesheet checks an exported timesheet against the key it embeds and returns it, only
+// comparing its Fingerprint to the one handed out by whoever signed it proves who did.
+func VerifyTimesheet(data []byte) (*Timesheet, error) {
+	var signed signedTimesheet
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("decoding signed timesheet: %w", err)
+	}
+	// indenting the file must not break the signature
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, signed.Timesheet); err != nil {
+		return nil, fmt.Errorf("decoding timesheet: %w", err)
+	}
+	var ts Timesheet
+	if err := json.Unmarshal(compact.Bytes(), &ts); err != nil {
+		return nil, fmt.Errorf("decoding timesheet: %w", err)
+	}
+	if len(ts.PublicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("timesheet public key has %d bytes, want %d", len(ts.PublicKey), ed25519.PublicKeySize)
+	}
+	if Fingerprint(ts.PublicKey) != ts.Fingerprint {
+		return nil, fmt.Errorf("timesheet fingerprint %s does not match its public key", ts.Fingerprint)
+	}
+	if !ed25519.Verify(ts.PublicKey, compact.Bytes(), signed.Signature) {
+		return nil, ErrBadSignature
+	}
+	return &ts, nil
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_ExportTimesheet(t *testing.T) {
+	s, task, entries := newChainStore(t)
+	customerID := task.Customer.ID
+	from, to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)
+	if _, err := s.ExportTimesheet(customerID, from, to); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Store.ExportTimesheet() without a key error = %v, want ErrNoKey", err)
+	}
+	fingerprint, err := s.GenerateKey()
+	if err != nil {
+		t.Fatalf("Store.GenerateKey() error = %v", err)
+	}
+	if _, err := s.GenerateKey(); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("Store.GenerateKey() twice error = %v, want ErrKeyExists", err)
+	}
+	// another machine only gets the public key through sync
+	private := filepath.Join(keysPath(s.Root()), "signing.key")
+	moved := private + ".elsewhere"
+	if err := os.Rename(private, moved); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if _, err := s.GenerateKey(); !errors.Is(err, ErrKeyElsewhere) {
+		t.Errorf("Store.GenerateKey() without the private key error = %v, want ErrKeyElsewhere", err)
+	}
+	if err := os.Rename(moved, private); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if err := s.SaveEntry(NewEntry(task, time.Date(2024, 1, 3, 18, 0, 0, 0, time.UTC))); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+
+	data, err := s.ExportTimesheet(customerID, from, to)
+	if err != nil {
+		t.Fatalf("Store.ExportTimesheet() error = %v", err)
+	}
+	ts, err := VerifyTimesheet(data)
+	if err != nil {
+		t.Fatalf("VerifyTimesheet() error = %v", err)
+	}
+	if ts.Fingerprint != fingerprint || ts.Customer != task.Customer.Name || ts.Total != "2h0m0s" || ts.ChainSeq != 4 {
+		t.Errorf("VerifyTimesheet() = %+v, want 2h of %s signed by %s at record 4", ts, task.Customer.Name, fingerprint)
+	}
+	if len(ts.Entries) != 2 || ts.Entries[0].ID != entries[0].ID || ts.Entries[1].ID != entries[1].ID {
+		t.Errorf("VerifyTimesheet() entries = %+v, want the two finished ones in range", ts.Entries)
+	}
+	tampered := bytes.Replace(data, []byte(`"duration": "1h0m0s"`), []byte(`"duration": "9h0m0s"`), 1)
+	if bytes.Equal(tampered, data) {
+		t.Fatalf("no duration to tamper with in %s", data)
+	}
+	if _, err := VerifyTimesheet(tampered); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("VerifyTimesheet() of a tampered timesheet error = %v, want ErrBadSignature", err)
+	}
+
+	rotated, err := s.RotateKey()
+	if err != nil {
+		t.Fatalf("Store.RotateKey() error = %v", err)
+	}
+	retired, err := s.RetiredKeys()
+	if err != nil {
+		t.Fatalf("Store.RetiredKeys() error = %v", err)
+	}
+	if rotated == fingerprint || len(retired) != 1 || Fingerprint(retired[0]) != fingerprint {
+		t.Errorf("Store.RotateKey() = %s, retired %d keys, want a new key and %s retired", rotated, len(retired), fingerprint)
+	}
+	if _, err := VerifyTimesheet(data); err != nil {
+		t.Errorf("VerifyTimesheet() signed by a retired key error = %v", err)
+	}
+	data, err = s.ExportTimesheet(customerID, from, to)
+	if err != nil {
+		t.Fatalf("Store.ExportTimesheet() error = %v", err)
+	}
+	if ts, err := VerifyTimesheet(data); err != nil || ts.Fingerprint != rotated {
+		t.Errorf("VerifyTimesheet() after rotation = %v, %v, want it signed by %s", ts, err, rotated)
+	}
+}
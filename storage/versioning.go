@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoVersionControl is returned by the history functions of a store opened without version control.
+var ErrNoVersionControl = errors.New("version control is not enabled")
+
+// dataGitignore keeps the files that only make sense while a process runs out of the data root repository.
+const dataGitignore = `.lock
+.*.tmp
+*-journal
+`
+
+// dataGitattributes routes the data files to the merge driver of the bac command when merged by a git binary.
+const dataGitattributes = `customers/*/metadata.json merge=` + mergeDriverName + `
+customers/*/tasks.json merge=` + mergeDriverName + `
+tasks/**/*.json merge=` + mergeDriverName + `
+ledger/*.jsonl merge=` + mergeDriverName + `
+active.json merge=` + mergeDriverName + `
+`
+
+// mergeDriverName is the name the merge driver is registered with in the data root git config.
+const mergeDriverName = "ballandchain"
+
+// mergeDriverCommand is how git runs the merge driver, see the merge-driver command of bac.
+const mergeDriverCommand = "bac merge-driver %O %A %B %P"
+
+// Revision is a commit of the data root.
+type Revision struct {
+	Hash    string
+	Message string
+	Author  string
+	When    time.Time
+}
+
+// gitRepo commits the data root, it works on the files directly without needing a git binary.
+type gitRepo struct {
+	root string
+	repo *git.Repository
+}
+
+// openGitRepo opens the repository of the data root, initializing it with the data already
+// there on first use. The root lock must be held exclusively.
+func openGitRepo(root string) (*gitRepo, error) {
+	repo, err := git.PlainOpen(root)
+	created := false
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainInit(root, false)
+		created = true
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening git repository in %s: %w", root, err)
+	}
+	for name, content := range map[string]string{".gitignore": dataGitignore, ".gitattributes": dataGitattributes} {
+		if err := writeIfMissing(filepath.Join(root, name), content); err != nil {
+			return nil, err
+		}
+	}
+	if err := registerMergeDriver(repo); err != nil {
+		return nil, err
+	}
+	g := &gitRepo{root: root, repo: repo}
+	if created {
+		if err := g.commit("start version control"); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// writeIfMissing writes content to path unless the file exists, existing files may have been customized.
+func writeIfMissing(path, content string) error {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return err
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, content)
+		return err
+	})
+}
+
+// registerMergeDriver declares the merge driver of the data files in the repository config
+// unless it already is, the config is not versioned so every clone registers it again.
+func registerMergeDriver(repo *git.Repository) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("reading git config: %w", err)
+	}
+	driver := cfg.Raw.Section("merge").Subsection(mergeDriverName)
+	if driver.Option("driver") != "" {
+		return nil
+	}
+	driver.SetOption("name", "Ball & Chain data files")
+	driver.SetOption("driver", mergeDriverCommand)
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("registering merge driver: %w", err)
+	}
+	return nil
+}
+
+// signature returns the author of the commits made on this machine.
+func signature() *object.Signature {
+	name := "ballandchain"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return &object.Signature{Name: name, Email: name + "@" + host, When: time.Now()}
+}
+
+// commit records every change in the data root with the given message, nothing is committed if nothing changed.
+func (g *gitRepo) commit(message string) error {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("opening worktree: %w", err)
+	}
+	if err := w.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return fmt.Errorf("staging changes: %w", err)
+	}
+	status, err := w.Status()
+	if err != nil {
+		return fmt.Errorf("reading status: %w", err)
+	}
+	if status.IsClean() {
+		return nil
+	}
+	if _, err := w.Commit(message, &git.CommitOptions{Author: signature()}); err != nil {
+		return fmt.Errorf("committing %q: %w", message, err)
+	}
+	return nil
+}
+
+// history returns up to limit revisions, the most recent first, limit 0 means all of them.
+func (g *gitRepo) history(limit int) ([]Revision, error) {
+	commits, err := g.repo.Log(&git.LogOptions{})
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// nothing committed yet
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading log: %w", err)
+	}
+	defer commits.Close()
+	var revisions []Revision
+	for limit == 0 || len(revisions) < limit {
+		c, err := commits.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading log: %w", err)
+		}
+		revisions = append(revisions, Revision{
+			Hash:    c.Hash.String(),
+			Message: c.Message,
+			Author:  c.Author.Name,
+			When:    c.Author.When,
+		})
+	}
+	return revisions, nil
+}
+
+// restore makes the tracked files of the data root match the given revision, the history is
+// kept, the caller commits the result as a new revision.
+func (g *gitRepo) restore(revision string) error {
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return fmt.Errorf("resolving revision %s: %w", revision, err)
+	}
+	target, err := g.tree(*hash)
+	if err != nil {
+		return err
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return fmt.Errorf("reading HEAD: %w", err)
+	}
+	current, err := g.tree(head.Hash())
+	if err != nil {
+		return err
+	}
+	// files tracked now but not back then go away
+	err = current.Files().ForEach(func(f *object.File) error {
+		if _, err := target.File(f.Name); !errors.Is(err, object.ErrFileNotFound) {
+			return err
+		}
+		path := filepath.Join(g.root, filepath.FromSlash(f.Name))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+		removeEmptyDirs(g.root, filepath.Dir(path))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return target.Files().ForEach(func(f *object.File) error {
+		path := filepath.Join(g.root, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return fmt.Errorf("could not create directory %s: %w", filepath.Dir(path), err)
+		}
+		return writeFileAtomic(path, func(w io.Writer) error {
+			r, err := f.Reader()
+			if err != nil {
+				return fmt.Errorf("reading %s at %s: %w", f.Name, revision, err)
+			}
+			defer r.Close()
+			_, err = io.Copy(w, r)
+			return err
+		})
+	})
+}
+
+// tree returns the tree of the commit with the given hash.
+func (g *gitRepo) tree(hash plumbing.Hash) (*object.Tree, error) {
+	c, err := g.repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("reading commit %s: %w", hash, err)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("reading tree of %s: %w", hash, err)
+	}
+	return tree, nil
+}
+
+// removeEmptyDirs removes dir and its parents up to root as long as they are empty.
+func removeEmptyDirs(root, dir string) {
+	for dir != root && len(dir) > len(root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// History returns up to limit revisions of the data root, the most recent first, limit 0 means all of them.
+func (s *Store) History(limit int) ([]Revision, error) {
+	if s.git == nil {
+		return nil, ErrNoVersionControl
+	}
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.git.history(limit)
+}
+
+// Restore brings the data root back to the given revision and commits that as a new revision,
+// so the restore itself can be undone. Only the json backend can be restored while open.
+func (s *Store) Restore(revision string) error {
+	if s.git == nil {
+		return ErrNoVersionControl
+	}
+	if _, ok := s.backend.(*JSONBackend); !ok {
+		return errors.New("restoring is only supported by the json backend")
+	}
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.git.restore(revision); err != nil {
+		return err
+	}
+	if err := s.commit("restore %s", revision); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+// commit records the changes made to the data root when version control is enabled and syncs
+// them when online, the root lock must be held exclusively.
+func (s *Store) commit(format string, args ...any) error {
+	if s.git == nil {
+		return nil
+	}
+	if err := s.git.commit(fmt.Sprintf(format, args...)); err != nil {
+		return err
+	}
+	if s.online {
+		// the change is safe locally, it goes out with the next sync if this one fails
+		if err := s.sync(); err != nil {
+			s.warn(fmt.Errorf("could not sync %s, working offline: %w", s.root, err))
+		}
+	}
+	return nil
+}
+
+// describeEntry names an entry along with its task and customer for commit messages.
+func (s *Store) describeEntry(e *Entry) string {
+	ref := e.reference()
+	task, customer := ref.TaskID.String(), ref.CustomerID.String()
+	if t, err := s.Task(ref.CustomerID, ref.TaskID); err == nil {
+		task = t.Name
+	}
+	if c, err := s.Customer(ref.CustomerID); err == nil {
+		customer = c.Name
+	}
+	return fmt.Sprintf("%s on %s for %s", e.ID, task, customer)
+}
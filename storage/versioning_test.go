@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"errors"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_VersionControl(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root, Options{VersionControl: true})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	customer := NewCustomer("Test Customer")
+	if err := s.AddCustomer(customer); err != nil {
+		t.Fatalf("Store.AddCustomer() error = %v", err)
+	}
+	ct, err := s.LoadTasks(customer.ID)
+	if err != nil {
+		t.Fatalf("Store.LoadTasks() error = %v", err)
+	}
+	task := &Task{ID: uuid.New(), Customer: customer, Name: "Design"}
+	if err := ct.AddTask(task); err != nil {
+		t.Fatalf("CustomerTasks.AddTask() error = %v", err)
+	}
+	if err := s.SaveTasks(ct); err != nil {
+		t.Fatalf("Store.SaveTasks() error = %v", err)
+	}
+	e := NewEntry(task, time.Now().Add(-time.Hour))
+	if err := s.SaveEntry(e); err != nil {
+		t.Fatalf("Store.SaveEntry() error = %v", err)
+	}
+	if err := s.FinishEntryAt(e, e.StartTS.Add(time.Minute)); err != nil {
+		t.Fatalf("Store.FinishEntryAt() error = %v", err)
+	}
+	if err := s.DeleteEntry(e); err != nil {
+		t.Fatalf("Store.DeleteEntry() error = %v", err)
+	}
+
+	history, err := s.History(0)
+	if err != nil {
+		t.Fatalf("Store.History() error = %v", err)
+	}
+	entry := e.ID.String() + " on Design for Test Customer"
+	want := []string{
+		"delete entry " + entry,
+		"finish entry " + entry,
+		"start entry " + entry,
+		"save 1 tasks for Test Customer",
+		"save customer Test Customer",
+		"start version control",
+	}
+	if len(history) != len(want) {
+		t.Fatalf("Store.History() = %v, want %d revisions", history, len(want))
+	}
+	for i, r := range history {
+		if r.Message != want[i] {
+			t.Errorf("revision %d message = %q, want %q", i, r.Message, want[i])
+		}
+	}
+	if limited, err := s.History(2); err != nil || len(limited) != 2 {
+		t.Errorf("Store.History(2) = %d revisions, %v, want 2", len(limited), err)
+	}
+	tree, err := s.git.tree(plumbing.NewHash(history[0].Hash))
+	if err != nil {
+		t.Fatalf("reading tree: %v", err)
+	}
+	if _, err := tree.File(lockFileName); err == nil {
+		t.Errorf("lock file %s was committed", lockFileName)
+	}
+
+	// back to before the tasks were saved and then forward again
+	if err := s.Restore(history[4].Hash); err != nil {
+		t.Fatalf("Store.Restore() error = %v", err)
+	}
+	if _, err := s.Task(customer.ID, task.ID); err == nil {
+		t.Errorf("Store.Task() found %s after restoring to before it existed", task.Name)
+	}
+	if ct, err := s.LoadTasks(customer.ID); err != nil || len(ct.Tasks) != 0 {
+		t.Errorf("Store.LoadTasks() after restore = %v, %v, want no tasks", ct, err)
+	}
+	if err := s.Restore(history[1].Hash); err != nil {
+		t.Fatalf("Store.Restore() error = %v", err)
+	}
+	got, err := s.LoadEntries(EntryFilter{})
+	if err != nil {
+		t.Fatalf("Store.LoadEntries() error = %v", err)
+	}
+	if len(got) != 1 || got[0].ID != e.ID || got[0].Task == nil || got[0].Task.Name != task.Name {
+		t.Errorf("Store.LoadEntries() after restore = %v, want the finished entry", got)
+	}
+	history, err = s.History(1)
+	if err != nil || !strings.HasPrefix(history[0].Message, "restore ") {
+		t.Errorf("Store.History() after restore = %v, %v, want a restore revision", history, err)
+	}
+}
+
+func TestStore_WithoutVersionControl(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	if _, err := s.History(0); !errors.Is(err, ErrNoVersionControl) {
+		t.Errorf("Store.History() error = %v, want %v", err, ErrNoVersionControl)
+	}
+}
@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchSettle is how long the data root has to stay quiet before changes are applied, a git pull
+// or an atomic write touches many files in a row.
+const watchSettle = 100 * time.Millisecond
+
+// ChangeKind tells what changed in the data root.
+type ChangeKind int
+
+// Changes reported by Store.Watch.
+const (
+	CustomerChanged ChangeKind = iota
+	CustomerRemoved
+	TasksChanged
+	EntryChanged
+	EntryRemoved
+)
+
+// String returns the name of the change.
+func (k ChangeKind) String() string {
+	switch k {
+	case CustomerChanged:
+		return "customer changed"
+	case CustomerRemoved:
+		return "customer removed"
+	case TasksChanged:
+		return "tasks changed"
+	case EntryChanged:
+		return "entry changed"
+	case EntryRemoved:
+		return "entry removed"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a change to the data root seen by Store.Watch once the caches and indexes reflect it.
+type Change struct {
+	Kind       ChangeKind
+	CustomerID uuid.UUID
+	EntryID    uuid.UUID // for entry changes
+	Entry      *Entry    // the entry as saved for EntryChanged
+	// Err is set, and the fields above are not, when the data root could not be watched or a
+	// change could not be applied, the caches may then be stale until the next change.
+	Err error
+}
+
+// Watch follows the customer, task and entry files of the data root, whoever changes them, and
+// keeps the caches and indexes of the store up to date. Every change applied, or failing to, is
+// sent on the returned channel, which is closed once ctx is done. Only the json backend can be
+// watched.
+func (s *Store) Watch(ctx context.Context) (<-chan Change, error) {
+	if _, ok := s.backend.(*JSONBackend); !ok {
+		return nil, errors.New("watching is only supported by the json backend")
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watching %s: %w", s.root, err)
+	}
+	for _, dir := range []string{"customers", "tasks"} {
+		path := filepath.Join(s.root, dir)
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("could not create directory %s: %w", path, err)
+		}
+		if _, err := watchTree(w, path); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	changes := make(chan Change, 64)
+	go s.watch(ctx, w, changes)
+	return changes, nil
+}
+
+// watchTree watches dir and every folder below it, it returns the data files found on the way
+// which may have been written before the watch started.
+func watchTree(w *fsnotify.Watcher, dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			if filepath.Ext(path) == ".json" {
+				files = append(files, path)
+			}
+			return nil
+		}
+		if err := w.Add(path); err != nil {
+			return fmt.Errorf("watching %s: %w", path, err)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// watch collects the file events until the data root settles and applies them.
+func (s *Store) watch(ctx context.Context, w *fsnotify.Watcher, changes chan<- Change) {
+	defer close(changes)
+	defer w.Close()
+	// send tells if the change was sent before ctx was done
+	send := func(c Change) bool {
+		select {
+		case changes <- c:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	pending := map[string]bool{}
+	settle := time.NewTimer(watchSettle)
+	settle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			if !send(Change{Err: fmt.Errorf("watching %s: %w", s.root, err)}) {
+				return
+			}
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if strings.HasSuffix(event.Name, tempSuffix) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					files, err := watchTree(w, event.Name)
+					if err != nil && !send(Change{Err: err}) {
+						return
+					}
+					for _, f := range files {
+						pending[f] = true
+					}
+				}
+			}
+			pending[event.Name] = true
+			settle.Reset(watchSettle)
+		case <-settle.C:
+			for _, c := range s.applyChanges(pending) {
+				if !send(c) {
+					return
+				}
+			}
+			pending = map[string]bool{}
+		}
+	}
+}
+
+// applyChanges brings the caches and indexes up to date with the changed paths, customers
+// first so their tasks and entries can be resolved. Paths failing to apply are reported as
+// changes holding the error.
+func (s *Store) applyChanges(pending map[string]bool) []Change {
+	paths := make([]string, 0, len(pending))
+	for path := range pending {
+		paths = append(paths, path)
+	}
+	// customers/ sorts before tasks/, metadata.json before tasks.json
+	sort.Strings(paths)
+	unlock, err := s.lock(false)
+	if err != nil {
+		return []Change{{Err: fmt.Errorf("could not apply changes to %s: %w", s.root, err)}}
+	}
+	defer unlock()
+	var changes []Change
+	for _, path := range paths {
+		change, ok, err := s.applyChange(path)
+		if err != nil {
+			changes = append(changes, Change{Err: fmt.Errorf("could not apply change of %s: %w", path, err)})
+			continue
+		}
+		if ok {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// applyChange updates the store after path changed, it reports false for paths holding no data.
+func (s *Store) applyChange(path string) (Change, bool, error) {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return Change{}, false, err
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 2 {
+		return Change{}, false, nil
+	}
+	customerID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return Change{}, false, nil
+	}
+	switch {
+	case parts[0] == "customers" && (len(parts) == 2 || parts[2] == "metadata.json"):
+		return s.applyCustomer(customerID)
+	case parts[0] == "customers" && parts[2] == "tasks.json":
+		return s.applyTasks(customerID)
+	case parts[0] == "tasks" && len(parts) == 6 && filepath.Ext(path) == ".json":
+		entryID, err := uuid.Parse(strings.TrimSuffix(parts[5], ".json"))
+		if err != nil {
+			return Change{}, false, nil
+		}
+		return s.applyEntry(customerID, entryID, path)
+	case parts[0] == "tasks" && len(parts) < 6 && !exists(path):
+		// a whole folder of entries went away
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.commentIndex != nil {
+			l, err := loadLedger(s.root, customerID)
+			if err != nil {
+				return Change{}, false, err
+			}
+			_, head := l.head()
+			if err := s.indexCustomerComments(s.commentIndex, customerID, head); err != nil {
+				return Change{}, false, err
+			}
+		}
+		return Change{Kind: EntryRemoved, CustomerID: customerID}, true, nil
+	}
+	return Change{}, false, nil
+}
+
+// applyCustomer reloads the metadata of a customer, forgetting it if it was removed.
+func (s *Store) applyCustomer(id uuid.UUID) (Change, bool, error) {
+	c, err := s.backend.LoadCustomer(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errors.Is(err, ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		if _, ok := s.customers[id]; !ok {
+			return Change{}, false, nil
+		}
+		if err := s.removeTaskIndex(id); err != nil {
+			return Change{}, false, err
+		}
+		if s.commentIndex != nil {
+			if err := deleteCustomerComments(s.commentIndex, id); err != nil {
+				return Change{}, false, err
+			}
+		}
+		delete(s.tasks, id)
+		delete(s.customers, id)
+		return Change{Kind: CustomerRemoved, CustomerID: id}, true, nil
+	}
+	if err != nil {
+		return Change{}, false, err
+	}
+	if _, err := s.ensureIndex(c); err != nil {
+		return Change{}, false, err
+	}
+	if _, ok := s.tasks[id]; !ok {
+		s.tasks[id] = map[uuid.UUID]Task{}
+	}
+	s.setCustomer(c)
+	return Change{Kind: CustomerChanged, CustomerID: id}, true, nil
+}
+
+// applyTasks reloads the tasks of a customer and rebuilds their index if they changed.
+func (s *Store) applyTasks(customerID uuid.UUID) (Change, bool, error) {
+	c, err := s.Customer(customerID)
+	if err != nil {
+		// tasks of a customer not there yet or anymore
+		return Change{}, false, nil
+	}
+	ct, err := s.backend.LoadTasks(c)
+	if err != nil {
+		return Change{}, false, err
+	}
+	if err := s.refreshTaskIndex(c, ct.Tasks); err != nil {
+		return Change{}, false, err
+	}
+	s.mu.Lock()
+	s.setTasks(c, ct.Tasks)
+	s.mu.Unlock()
+	return Change{Kind: TasksChanged, CustomerID: customerID}, true, nil
+}
+
+// applyEntry indexes the comment of an entry file as it is now.
+func (s *Store) applyEntry(customerID, entryID uuid.UUID, path string) (Change, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// a move to another day folder shows up as a removal and a creation
+		if err := s.indexComment(&Entry{ID: entryID, ref: taskRef{CustomerID: customerID}}, true); err != nil {
+			return Change{}, false, err
+		}
+		return Change{Kind: EntryRemoved, CustomerID: customerID, EntryID: entryID}, true, nil
+	}
+	if err != nil {
+		return Change{}, false, fmt.Errorf("reading entry file: %w", err)
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Change{}, false, fmt.Errorf("decode entry file %s: %w", path, err)
+	}
+	if err := s.resolveEntry(&e); err != nil {
+		return Change{}, false, err
+	}
+	if err := s.indexComment(&e, false); err != nil {
+		return Change{}, false, err
+	}
+	return Change{Kind: EntryChanged, CustomerID: customerID, EntryID: entryID, Entry: &e}, true, nil
+}
+
+// indexComment updates the comment index, opening it if needed, after an entry file changed.
+func (s *Store) indexComment(e *Entry, deleted bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, err := s.comments()
+	if err != nil {
+		return err
+	}
+	if deleted || e.Comment == "" {
+		if err := index.Delete(e.ID.String()); err != nil {
+			return fmt.Errorf("removing comment of entry %s: %w", e.ID, err)
+		}
+		return nil
+	}
+	if err := index.Index(e.ID.String(), s.newCommentDocument(e)); err != nil {
+		return fmt.Errorf("indexing comment of entry %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+// exists tells if path is there.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
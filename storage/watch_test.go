@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_Watch(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root, Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := s.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Store.Watch() error = %v", err)
+	}
+	// waitFor skips other changes until one of the given kind shows up, or a failed one if kind is negative
+	waitFor := func(kind ChangeKind) Change {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case c := <-changes:
+				switch {
+				case c.Err != nil && kind < 0:
+					return c
+				case c.Err != nil:
+					t.Fatalf("waiting for %s: %v", kind, c.Err)
+				case c.Kind == kind:
+					return c
+				}
+			case <-timeout:
+				t.Fatalf("no %s within 5s", kind)
+			}
+		}
+	}
+
+	// what a git pull or a hand edit would do
+	c := NewCustomer("Pulled Customer")
+	if err := c.save(root); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitFor(CustomerChanged); got.CustomerID != c.ID {
+		t.Errorf("change for customer %s, want %s", got.CustomerID, c.ID)
+	}
+	if _, err := s.Customer(c.ID); err != nil {
+		t.Errorf("Store.Customer() error = %v after it was pulled", err)
+	}
+
+	task := &Task{ID: uuid.New(), Customer: c, Name: "Pulled task", ExternalID: "PRJ-9"}
+	if err := (&CustomerTasks{Customer: c, Tasks: []*Task{task}}).save(root); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(TasksChanged)
+	if matches, err := s.SearchTasks("prj-9", TaskSearchOptions{}); err != nil || len(matches) != 1 {
+		t.Errorf("Store.SearchTasks() = %v, %v, want the pulled task", matches, err)
+	}
+
+	e := NewEntry(task, time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC))
+	e.Comment = "pulled comment"
+	if err := e.save(root); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitFor(EntryChanged); got.EntryID != e.ID || got.Entry.Task == nil || got.Entry.Task.ID != task.ID {
+		t.Errorf("change = %+v, want entry %s of the pulled task", got, e.ID)
+	}
+	if results, err := s.SearchComments("pulled", CommentSearchOptions{}); err != nil || results.Total != 1 {
+		t.Errorf("Store.SearchComments() = %v, %v, want the pulled entry", results, err)
+	}
+
+	if err := os.Remove(s.backend.(*JSONBackend).entryPath(e)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(EntryRemoved)
+	if results, err := s.SearchComments("pulled", CommentSearchOptions{}); err != nil || results.Total != 0 {
+		t.Errorf("Store.SearchComments() = %v, %v, want nothing once removed", results, err)
+	}
+
+	// a broken file is reported on the channel
+	broken := filepath.Join(filepath.Dir(s.backend.(*JSONBackend).entryPath(e)), uuid.NewString()+".json")
+	if err := os.WriteFile(broken, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitFor(-1); !strings.Contains(got.Err.Error(), broken) {
+		t.Errorf("change error = %v, want one about %s", got.Err, broken)
+	}
+	if err := os.Remove(broken); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(c.SavePath(root)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(CustomerRemoved)
+	if _, err := s.Customer(c.ID); err == nil {
+		t.Errorf("Store.Customer() found the removed customer")
+	}
+
+	cancel()
+	for range changes {
+	}
+}